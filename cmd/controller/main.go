@@ -49,6 +49,7 @@ func main() {
 			op.InstanceProvider,
 			op.KubernetesVersionProvider,
 			op.InClusterKubernetesInterface,
+			op.CredentialSources,
 		)...).
 		Start(ctx)
 }
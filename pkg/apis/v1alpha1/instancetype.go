@@ -0,0 +1,56 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// Architecture returns the CPU architecture of the instance type, defaulting to amd64
+func (t InstanceType) Architecture() string {
+	if t.Arch == "" {
+		return karpv1.ArchitectureAmd64
+	}
+	return strings.ToLower(t.Arch)
+}
+
+// InstanceClass returns the resource class of the instance type, defaulting to burstable
+func (t InstanceType) InstanceClass() InstanceClass {
+	if t.Class == "" {
+		return InstanceClassBurstable
+	}
+	return t.Class
+}
+
+// Name returns the instance type name in the vSphere CPI format
+func (t InstanceType) Name() string {
+	mem := strings.TrimSuffix(t.Memory, "Gi")
+	name := fmt.Sprintf("vsphere-vm.cpu-%s.mem-%sgb.os-%s", t.CPU, mem, strings.ToLower(t.OS))
+	// Defaults keep the original format so existing instance types are not renamed
+	if arch := t.Architecture(); arch != karpv1.ArchitectureAmd64 {
+		name = fmt.Sprintf("%s.arch-%s", name, arch)
+	}
+	if class := t.InstanceClass(); class != InstanceClassBurstable {
+		name = fmt.Sprintf("%s.class-%s", name, class)
+	}
+	return name
+}
+
+// InstanceTypeByName returns the instance type definition generating the given name
+func (s VsphereNodeClassSpec) InstanceTypeByName(name string) (InstanceType, bool) {
+	for _, t := range s.InstanceTypes {
+		if t.Name() == name {
+			return t, true
+		}
+	}
+	return InstanceType{}, false
+}
+
+// CPUTopologyFor returns the CPU topology of the instance type, falling back to the NodeClass topology
+func (s VsphereNodeClassSpec) CPUTopologyFor(t InstanceType) *CPUTopology {
+	if t.Topology != nil {
+		return t.Topology
+	}
+	return s.Topology
+}
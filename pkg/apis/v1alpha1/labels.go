@@ -11,6 +11,11 @@ func init() {
 	karpv1.RestrictedLabelDomains = karpv1.RestrictedLabelDomains.Insert(RestrictedLabelDomains...)
 	karpv1.WellKnownLabels = karpv1.WellKnownLabels.Insert(
 		LabelInstanceSize,
+		LabelInstanceClass,
+		LabelInstanceCPUSockets,
+		LabelInstanceCoresPerSocket,
+		LabelESXiHost,
+		LabelComputeCluster,
 	)
 }
 
@@ -24,8 +29,17 @@ var (
 	LabelInstanceCPU                      = apis.Group + "/instance-cpu"
 	LabelInstanceMemory                   = apis.Group + "/instance-memory"
 	LabelInstanceSize                     = apis.Group + "/instance-size"
+	LabelInstanceClass                    = apis.Group + "/instance-class"
+	LabelInstanceCPUSockets               = apis.Group + "/instance-cpu-sockets"
+	LabelInstanceCoresPerSocket           = apis.Group + "/instance-cores-per-socket"
 	LabelInstanceType                     = corev1.LabelInstanceTypeStable
+	LabelESXiHost                         = apis.Group + "/esxi-host"
+	LabelComputeCluster                   = apis.Group + "/compute-cluster"
+	EncryptionTagKey                      = apis.Group + "/encryption-key-provider"
+	VCenterTagKey                         = apis.Group + "/vcenter"
+	AntiAffinityTagKey                    = apis.Group + "/anti-affinity-rule"
 	AnnotationVsphereNodeClassHashVersion = apis.Group + "/vspherenodeclass-hash-version"
+	AnnotationEncryption                  = apis.Group + "/encryption"
 	NodeClaimTagKey                       = coreapis.Group + "/nodeclaim"
 	NodePoolTagKey                        = karpv1.NodePoolLabelKey
 	ClusterNameTagKey                     = "karpenter.sh/clustername"
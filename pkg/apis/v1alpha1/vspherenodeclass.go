@@ -38,7 +38,8 @@ type ResPoolSelctorTerm struct {
 	// +kubebuilder:validation:XValidation:message="empty tag keys or values aren't supported",rule="self.all(k, k != '' && self[k] != '')"
 	// +optional
 	Tags map[string]string `json:"tags,omitempty"`
-	// Name is optional ResourcePoolName
+	// Name is the name of a cluster whose root resource pool is used, or the
+	// inventory path of a resource pool or vApp, e.g. host/cluster1/Resources/team-a/prod
 	// +optional
 	Name string `json:"name,omitempty"`
 }
@@ -64,13 +65,26 @@ type NetworkSelectorTerm struct {
 	// +optional
 	Name string `json:"name,omitempty"`
 }
+
+// +kubebuilder:validation:XValidation:message="expected at least one of tags or names",rule="has(self.tags) || has(self.names)"
+type HostSelectorTerm struct {
+	// Tags is a map of key/value tags used to select ESXi hosts, all
+	// hosts carrying every tag are selected
+	// +kubebuilder:validation:XValidation:message="empty tag keys or values aren't supported",rule="self.all(k, k != '' && self[k] != '')"
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// Names is a list of ESXi host names
+	// +optional
+	Names []string `json:"names,omitempty"`
+}
 type DCSelectorTerm struct {
-	// Tags is a map of key/value tags used to select subnets
+	// Tags is a map of key/value tags used to select the datacenter
 	// Specifying '*' for a value selects all values for a given tag key.
 	// +kubebuilder:validation:XValidation:message="empty tag keys or values aren't supported",rule="self.all(k, k != '' && self[k] != '')"
 	// +optional
 	Tags map[string]string `json:"tags,omitempty"`
-	// Name is optional DatacenterName
+	// Name is the datacenter name, the datacenter set by the vsphere-dc flag is used
+	// when neither name nor tags are set
 	// +optional
 	Name string `json:"id,omitempty"`
 }
@@ -83,8 +97,35 @@ type ImageSelectorTerm struct {
 	// Name is optional ImagePattern
 	// +optional
 	Pattern string `json:"pattern,omitempty"`
+	// Architectures maps a CPU architecture (amd64, arm64) to the template used
+	// for instance types of that architecture. Architectures without an entry
+	// fall back to Tags and Pattern.
+	// +optional
+	Architectures map[string]ArchImageSelectorTerm `json:"architectures,omitempty"`
 }
 
+type ArchImageSelectorTerm struct {
+	// Tags is a map of key/value tags used to select the template
+	// +kubebuilder:validation:XValidation:message="empty tag keys or values aren't supported",rule="self.all(k, k != '' && self[k] != '')"
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// Pattern is optional ImagePattern
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// ForArch returns the image selector for the given architecture, falling back
+// to the default selector when no architecture specific entry exists.
+func (s ImageSelectorTerm) ForArch(arch string) ImageSelectorTerm {
+	term, ok := s.Architectures[arch]
+	if !ok {
+		return s
+	}
+	return ImageSelectorTerm{Tags: term.Tags, Pattern: term.Pattern}
+}
+
+// +kubebuilder:validation:XValidation:message="drsPlacement can't be combined with hostSelector",rule="!has(self.drsPlacement) || !self.drsPlacement || !has(self.hostSelector)"
+// +kubebuilder:validation:XValidation:message="rootDisk.storagePolicy must be the encryption storage policy",rule="!has(self.encryption) || !has(self.encryption.storagePolicy) || !has(self.rootDisk) || !has(self.rootDisk.storagePolicy) || self.rootDisk.storagePolicy == self.encryption.storagePolicy"
 type VsphereNodeClassSpec struct {
 	PoolSelector      ResPoolSelctorTerm    `json:"computeSelector,omitempty"`
 	NetworkSelector   NetworkSelectorTerm   `json:"networkSelector,omitempty"`
@@ -96,6 +137,185 @@ type VsphereNodeClassSpec struct {
 	UserData          UserData              `json:"userData,omitempty"`
 	K8sDistro         Distro                `json:"k8SDistro,omitempty"`
 	Tags              map[string]string     `json:"tags,omitempty"`
+	// GuestOS controls the guest identifier, firmware and security devices of the VMs
+	// +optional
+	GuestOS GuestOS `json:"guestOS,omitempty"`
+	// Windows configures the Sysprep guest customisation of instance types with os windows
+	// +optional
+	Windows *WindowsCustomization `json:"windows,omitempty"`
+	// Topology is the default CPU topology of all instance types
+	// +optional
+	Topology *CPUTopology `json:"topology,omitempty"`
+	// ExtraConfig is a map of VMX advanced settings applied to the VMs.
+	// The guestinfo userdata, metadata and ignition keys and the karpenter.vsphere.cluster marker are managed by the provider and can't be set.
+	// +kubebuilder:validation:XValidation:message="guestinfo.userdata, guestinfo.metadata, guestinfo.ignition and karpenter.vsphere.cluster keys are managed by the provider",rule="self.all(k, !k.startsWith('guestinfo.userdata') && !k.startsWith('guestinfo.metadata') && !k.startsWith('guestinfo.ignition.') && !k.startsWith('karpenter.vsphere.cluster'))"
+	// +optional
+	ExtraConfig map[string]string `json:"extraConfig,omitempty"`
+	// RootDisk controls provisioning and placement of the cloned root disk
+	// +optional
+	RootDisk RootDisk `json:"rootDisk,omitempty"`
+	// Encryption encrypts the VM home and root disk when cloning
+	// +optional
+	Encryption *Encryption `json:"encryption,omitempty"`
+	// AntiAffinity spreads the VMs of a NodePool across the ESXi hosts of the
+	// DRS cluster with a VM anti-affinity rule
+	// +optional
+	AntiAffinity *AntiAffinity `json:"antiAffinity,omitempty"`
+	// HostSelector restricts the VMs to the selected ESXi hosts of the compute
+	// cluster with a mandatory DRS VM-Host rule, or places them directly on one
+	// of the hosts when DRS is disabled
+	// +optional
+	HostSelector *HostSelectorTerm `json:"hostSelector,omitempty"`
+	// DRSPlacement asks DRS for the host and datastore of every new VM before
+	// cloning, launches DRS can't place fail with insufficient capacity
+	// +optional
+	DRSPlacement bool `json:"drsPlacement,omitempty"`
+	// VCenter is the name of an endpoint from the vsphere-endpoints-config to
+	// provision into, the vCenter of the vsphere-endpoint flag when not set
+	// +optional
+	VCenter string `json:"vcenter,omitempty"`
+}
+
+type AffinityEnforcement string
+
+const (
+	AffinityEnforcementMust   AffinityEnforcement = "must"
+	AffinityEnforcementShould AffinityEnforcement = "should"
+)
+
+type AntiAffinity struct {
+	// Enforcement is must for a mandatory rule, DRS doesn't power on VMs
+	// that would break it, or should for a best effort rule
+	// +kubebuilder:validation:Enum=must;should
+	// +kubebuilder:default=should
+	// +optional
+	Enforcement AffinityEnforcement `json:"enforcement,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:message="exactly one of keyProvider or storagePolicy must be set",rule="has(self.keyProvider) != has(self.storagePolicy)"
+type Encryption struct {
+	// KeyProvider is the ID of the vSphere key provider generating the VM encryption key
+	// +optional
+	KeyProvider string `json:"keyProvider,omitempty"`
+	// StoragePolicy is the name of a storage policy with the VM encryption rule,
+	// applied to the VM home and root disk. rootDisk.storagePolicy can only name the same policy
+	// +optional
+	StoragePolicy string `json:"storagePolicy,omitempty"`
+}
+
+// ID returns the key provider or storage policy the VM is encrypted with,
+// empty when encryption is not configured
+func (e *Encryption) ID() string {
+	if e == nil {
+		return ""
+	}
+	if e.KeyProvider != "" {
+		return e.KeyProvider
+	}
+	return e.StoragePolicy
+}
+
+type DiskProvisioning string
+
+const (
+	DiskProvisioningThin             DiskProvisioning = "thin"
+	DiskProvisioningThick            DiskProvisioning = "thick"
+	DiskProvisioningEagerZeroedThick DiskProvisioning = "eagerZeroedThick"
+)
+
+type RootDisk struct {
+	// Provisioning is the disk format of the root disk, thick is lazy zeroed.
+	// The template disk format is kept when not set
+	// +kubebuilder:validation:Enum=thin;thick;eagerZeroedThick
+	// +optional
+	Provisioning DiskProvisioning `json:"provisioning,omitempty"`
+	// DatastoreSelector places the root disk on a different datastore than the VM
+	// +optional
+	DatastoreSelector *DatastoreSelectorTerm `json:"datastoreSelector,omitempty"`
+	// StoragePolicy is the name of the storage policy applied to the root disk
+	// +optional
+	StoragePolicy string `json:"storagePolicy,omitempty"`
+}
+
+// IsZero reports whether the root disk keeps the template settings
+func (d RootDisk) IsZero() bool {
+	return d.Provisioning == "" && d.DatastoreSelector == nil && d.StoragePolicy == ""
+}
+
+type CPUTopology struct {
+	// CoresPerSocket is the number of cores per virtual socket,
+	// the instance type CPU count must be a multiple of it
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	CoresPerSocket int32 `json:"coresPerSocket,omitempty"`
+	// CPUHotAdd allows adding vCPUs to a running VM, enabling it disables vNUMA
+	// +optional
+	CPUHotAdd bool `json:"cpuHotAdd,omitempty"`
+	// MemoryHotAdd allows adding memory to a running VM
+	// +optional
+	MemoryHotAdd bool `json:"memoryHotAdd,omitempty"`
+	// MaxVCPUsPerNUMANode sets numa.vcpu.maxPerVirtualNode
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxVCPUsPerNUMANode int32 `json:"maxVCPUsPerNUMANode,omitempty"`
+	// NUMAMinVCPUs sets numa.vcpu.min, the vCPU count from which vNUMA is exposed to the guest
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	NUMAMinVCPUs int32 `json:"numaMinVCPUs,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:message="expected one of customizationSpec or adminPasswordSecretName",rule="has(self.customizationSpec) || has(self.adminPasswordSecretName)"
+type WindowsCustomization struct {
+	// CustomizationSpec is the name of a customization spec stored in vCenter.
+	// When set it is used instead of the generated Sysprep spec and the node
+	// join command is appended to its run once commands.
+	// +optional
+	CustomizationSpec string `json:"customizationSpec,omitempty"`
+	// AdminPasswordSecretName is the name of a Secret in the controller namespace
+	// whose password key holds the local Administrator password, required unless
+	// customizationSpec is set
+	// +optional
+	AdminPasswordSecretName string `json:"adminPasswordSecretName,omitempty"`
+	// TimeZone is the Windows time zone index, defaults to 85 (GMT)
+	// +optional
+	TimeZone int32 `json:"timeZone,omitempty"`
+	// Organization is the organization name registered by Sysprep
+	// +optional
+	Organization string `json:"organization,omitempty"`
+	// ProductKey is the Windows product key, KMS activated images can leave it empty
+	// +optional
+	ProductKey string `json:"productKey,omitempty"`
+}
+
+type Firmware string
+
+const (
+	FirmwareBIOS Firmware = "bios"
+	FirmwareEFI  Firmware = "efi"
+)
+
+// +kubebuilder:validation:XValidation:message="secureBoot requires efi firmware",rule="!has(self.secureBoot) || !self.secureBoot || (has(self.firmware) && self.firmware == 'efi')"
+// +kubebuilder:validation:XValidation:message="vtpm requires efi firmware",rule="!has(self.vtpm) || !self.vtpm || (has(self.firmware) && self.firmware == 'efi')"
+type GuestOS struct {
+	// GuestID is the vSphere guest OS identifier, e.g. ubuntu64Guest.
+	// Defaults to a generic Linux identifier matching the instance type architecture.
+	// +optional
+	GuestID string `json:"guestId,omitempty"`
+	// Firmware of the VM, inherited from the template when not set
+	// +kubebuilder:validation:Enum=bios;efi
+	// +optional
+	Firmware Firmware `json:"firmware,omitempty"`
+	// SecureBoot enables or disables UEFI Secure Boot, inherited from the template when not set
+	// +optional
+	SecureBoot *bool `json:"secureBoot,omitempty"`
+	// VTPM adds a virtual TPM device, vCenter must have a key provider configured.
+	// With a hardware version it is added once the VM is upgraded
+	// +optional
+	VTPM bool `json:"vtpm,omitempty"`
+	// HardwareVersion upgrades the cloned VM to the given virtual hardware version, e.g. vmx-19
+	// +kubebuilder:validation:Pattern=`^vmx-[0-9]+$`
+	// +optional
+	HardwareVersion string `json:"hardwareVersion,omitempty"`
 }
 
 type UserDataType string
@@ -124,6 +344,49 @@ type InstanceType struct {
 	Arch    string `json:"arch,omitempty"`
 	Zone    string `json:"zone,omitempty"`
 	Region  string `json:"region,omitempty"`
+	// Class is the VM resource class, guaranteed reserves all of the VM memory.
+	// Exposed as the karpenter.vsphere.com/instance-class label, defaults to burstable
+	// +kubebuilder:validation:Enum=guaranteed;burstable
+	// +optional
+	Class InstanceClass `json:"class,omitempty"`
+	// CPUAllocation sets the CPU reservation and limit in MHz and the CPU shares
+	// +optional
+	CPUAllocation *ResourceAllocation `json:"cpuAllocation,omitempty"`
+	// MemoryAllocation sets the memory reservation and limit in MB and the memory shares
+	// +optional
+	MemoryAllocation *ResourceAllocation `json:"memoryAllocation,omitempty"`
+	// LatencySensitivity of the VM, high requires a full memory reservation
+	// +kubebuilder:validation:Enum=normal;high
+	// +optional
+	LatencySensitivity string `json:"latencySensitivity,omitempty"`
+	// Topology overrides the NodeClass CPU topology for this instance type
+	// +optional
+	Topology *CPUTopology `json:"topology,omitempty"`
+}
+
+type InstanceClass string
+
+const (
+	InstanceClassGuaranteed InstanceClass = "guaranteed"
+	InstanceClassBurstable  InstanceClass = "burstable"
+)
+
+type ResourceAllocation struct {
+	// Reservation guaranteed to the VM, MHz for CPU and MB for memory
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Reservation *int64 `json:"reservation,omitempty"`
+	// Limit of the VM, MHz for CPU and MB for memory. -1 means unlimited
+	// +kubebuilder:validation:Minimum=-1
+	// +optional
+	Limit *int64 `json:"limit,omitempty"`
+	// SharesLevel is the relative priority under contention
+	// +kubebuilder:validation:Enum=low;normal;high;custom
+	// +optional
+	SharesLevel string `json:"sharesLevel,omitempty"`
+	// Shares is the number of shares when SharesLevel is custom
+	// +optional
+	Shares int32 `json:"shares,omitempty"`
 }
 
 func (nc *VsphereNodeClass) Hash() string {
@@ -0,0 +1,40 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestImageSelectorForArch(t *testing.T) {
+	selector := ImageSelectorTerm{
+		Tags:    map[string]string{"os": "ubuntu"},
+		Pattern: "ubuntu-*",
+		Architectures: map[string]ArchImageSelectorTerm{
+			karpv1.ArchitectureArm64: {Pattern: "ubuntu-arm-*"},
+		},
+	}
+	tests := []struct {
+		name     string
+		arch     string
+		expected ImageSelectorTerm
+	}{
+		{
+			name:     "architecture entry replaces the default selector",
+			arch:     karpv1.ArchitectureArm64,
+			expected: ImageSelectorTerm{Pattern: "ubuntu-arm-*"},
+		},
+		{
+			name:     "architecture without an entry falls back to the default selector",
+			arch:     karpv1.ArchitectureAmd64,
+			expected: selector,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, selector.ForArch(test.arch))
+		})
+	}
+}
@@ -9,6 +9,58 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AntiAffinity) DeepCopyInto(out *AntiAffinity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AntiAffinity.
+func (in *AntiAffinity) DeepCopy() *AntiAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(AntiAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArchImageSelectorTerm) DeepCopyInto(out *ArchImageSelectorTerm) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchImageSelectorTerm.
+func (in *ArchImageSelectorTerm) DeepCopy() *ArchImageSelectorTerm {
+	if in == nil {
+		return nil
+	}
+	out := new(ArchImageSelectorTerm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CPUTopology) DeepCopyInto(out *CPUTopology) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPUTopology.
+func (in *CPUTopology) DeepCopy() *CPUTopology {
+	if in == nil {
+		return nil
+	}
+	out := new(CPUTopology)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DCSelectorTerm) DeepCopyInto(out *DCSelectorTerm) {
 	*out = *in
@@ -53,6 +105,68 @@ func (in *DatastoreSelectorTerm) DeepCopy() *DatastoreSelectorTerm {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Encryption) DeepCopyInto(out *Encryption) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Encryption.
+func (in *Encryption) DeepCopy() *Encryption {
+	if in == nil {
+		return nil
+	}
+	out := new(Encryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GuestOS) DeepCopyInto(out *GuestOS) {
+	*out = *in
+	if in.SecureBoot != nil {
+		in, out := &in.SecureBoot, &out.SecureBoot
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GuestOS.
+func (in *GuestOS) DeepCopy() *GuestOS {
+	if in == nil {
+		return nil
+	}
+	out := new(GuestOS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostSelectorTerm) DeepCopyInto(out *HostSelectorTerm) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostSelectorTerm.
+func (in *HostSelectorTerm) DeepCopy() *HostSelectorTerm {
+	if in == nil {
+		return nil
+	}
+	out := new(HostSelectorTerm)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSelectorTerm) DeepCopyInto(out *ImageSelectorTerm) {
 	*out = *in
@@ -63,6 +177,13 @@ func (in *ImageSelectorTerm) DeepCopyInto(out *ImageSelectorTerm) {
 			(*out)[key] = val
 		}
 	}
+	if in.Architectures != nil {
+		in, out := &in.Architectures, &out.Architectures
+		*out = make(map[string]ArchImageSelectorTerm, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSelectorTerm.
@@ -78,6 +199,21 @@ func (in *ImageSelectorTerm) DeepCopy() *ImageSelectorTerm {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InstanceType) DeepCopyInto(out *InstanceType) {
 	*out = *in
+	if in.CPUAllocation != nil {
+		in, out := &in.CPUAllocation, &out.CPUAllocation
+		*out = new(ResourceAllocation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MemoryAllocation != nil {
+		in, out := &in.MemoryAllocation, &out.MemoryAllocation
+		*out = new(ResourceAllocation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Topology != nil {
+		in, out := &in.Topology, &out.Topology
+		*out = new(CPUTopology)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceType.
@@ -134,6 +270,51 @@ func (in *ResPoolSelctorTerm) DeepCopy() *ResPoolSelctorTerm {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceAllocation) DeepCopyInto(out *ResourceAllocation) {
+	*out = *in
+	if in.Reservation != nil {
+		in, out := &in.Reservation, &out.Reservation
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceAllocation.
+func (in *ResourceAllocation) DeepCopy() *ResourceAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RootDisk) DeepCopyInto(out *RootDisk) {
+	*out = *in
+	if in.DatastoreSelector != nil {
+		in, out := &in.DatastoreSelector, &out.DatastoreSelector
+		*out = new(DatastoreSelectorTerm)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RootDisk.
+func (in *RootDisk) DeepCopy() *RootDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(RootDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UserData) DeepCopyInto(out *UserData) {
 	*out = *in
@@ -219,7 +400,9 @@ func (in *VsphereNodeClassSpec) DeepCopyInto(out *VsphereNodeClassSpec) {
 	if in.InstanceTypes != nil {
 		in, out := &in.InstanceTypes, &out.InstanceTypes
 		*out = make([]InstanceType, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	out.UserData = in.UserData
 	if in.Tags != nil {
@@ -229,6 +412,40 @@ func (in *VsphereNodeClassSpec) DeepCopyInto(out *VsphereNodeClassSpec) {
 			(*out)[key] = val
 		}
 	}
+	in.GuestOS.DeepCopyInto(&out.GuestOS)
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = new(WindowsCustomization)
+		**out = **in
+	}
+	if in.Topology != nil {
+		in, out := &in.Topology, &out.Topology
+		*out = new(CPUTopology)
+		**out = **in
+	}
+	if in.ExtraConfig != nil {
+		in, out := &in.ExtraConfig, &out.ExtraConfig
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.RootDisk.DeepCopyInto(&out.RootDisk)
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(Encryption)
+		**out = **in
+	}
+	if in.AntiAffinity != nil {
+		in, out := &in.AntiAffinity, &out.AntiAffinity
+		*out = new(AntiAffinity)
+		**out = **in
+	}
+	if in.HostSelector != nil {
+		in, out := &in.HostSelector, &out.HostSelector
+		*out = new(HostSelectorTerm)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VsphereNodeClassSpec.
@@ -262,3 +479,18 @@ func (in *VsphereNodeClassStatus) DeepCopy() *VsphereNodeClassStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WindowsCustomization) DeepCopyInto(out *WindowsCustomization) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WindowsCustomization.
+func (in *WindowsCustomization) DeepCopy() *WindowsCustomization {
+	if in == nil {
+		return nil
+	}
+	out := new(WindowsCustomization)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -34,6 +34,7 @@ const (
 	InstanceTypeResolutionFailedReason                           = "InstanceTypeResolutionFailed"
 	CreateInstanceFailedReason                                   = "CreateInstanceFailed"
 	NodeClassDrift                     cloudprovider.DriftReason = "NodeClassDrift"
+	EncryptionDrift                    cloudprovider.DriftReason = "EncryptionDrift"
 )
 
 type CloudProvider struct {
@@ -122,6 +123,15 @@ func (c *CloudProvider) instanceToNodeClaim(i *instance.Instance, instanceType *
 	if v, ok := i.Tags[karpv1.NodePoolLabelKey]; ok {
 		labels[karpv1.NodePoolLabelKey] = v
 	}
+	if i.Host != "" {
+		labels[v1alpha1.LabelESXiHost] = utils.SanitizeLabelValue(i.Host)
+	}
+	if i.ComputeCluster != "" {
+		labels[v1alpha1.LabelComputeCluster] = utils.SanitizeLabelValue(i.ComputeCluster)
+	}
+
+	// the encryption the VM was created with, compared on drift without asking vCenter
+	annotations[v1alpha1.AnnotationEncryption] = i.Tags[v1alpha1.EncryptionTagKey]
 
 	nodeClaim.Name = GenerateNodeClaimName(i.Name, i.Tags[v1alpha1.ClusterNameTagKey])
 	nodeClaim.Labels = labels
@@ -275,21 +285,18 @@ func (c *CloudProvider) IsDrifted(ctx context.Context, claim *karpv1.NodeClaim)
 	return driftReason, nil
 }
 
-func toCPITypeFormat(cpu, mem, os string) string {
-	mem = strings.TrimSuffix(mem, "Gi")
-	return fmt.Sprintf("vsphere-vm.cpu-%s.mem-%sgb.os-%s", cpu, mem, os)
-}
 func instanceTypesFromNodeClass(nodeClass *v1alpha1.VsphereNodeClass) []*cloudprovider.InstanceType {
 	instanceTypes := []*cloudprovider.InstanceType{}
 	for _, t := range nodeClass.Spec.InstanceTypes {
 		os := strings.ToLower(t.OS)
-		typeName := toCPITypeFormat(t.CPU, t.Memory, os)
+		typeName := t.Name()
 		instanceType := &cloudprovider.InstanceType{
 			Name: typeName,
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(corev1.LabelInstanceTypeStable, corev1.NodeSelectorOpIn, typeName),
-				scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, "amd64"),
+				scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, t.Architecture()),
 				scheduling.NewRequirement(corev1.LabelOSStable, corev1.NodeSelectorOpIn, os),
+				scheduling.NewRequirement(v1alpha1.LabelInstanceClass, corev1.NodeSelectorOpIn, string(t.InstanceClass())),
 			),
 			Capacity: corev1.ResourceList{
 				corev1.ResourceCPU:              resource.MustParse(t.CPU),
@@ -308,6 +315,19 @@ func instanceTypesFromNodeClass(nodeClass *v1alpha1.VsphereNodeClass) []*cloudpr
 				},
 			},
 		}
+		// Expose the socket layout so topology manager aware pods can target it
+		if topology := nodeClass.Spec.CPUTopologyFor(t); topology != nil && topology.CoresPerSocket > 0 {
+			cpus := instanceType.Capacity.Cpu().Value()
+			cores := int64(topology.CoresPerSocket)
+			// the CPUs can't be split into sockets, every launch would fail
+			if cpus%cores != 0 {
+				continue
+			}
+			instanceType.Requirements.Add(
+				scheduling.NewRequirement(v1alpha1.LabelInstanceCPUSockets, corev1.NodeSelectorOpIn, fmt.Sprint(cpus/cores)),
+				scheduling.NewRequirement(v1alpha1.LabelInstanceCoresPerSocket, corev1.NodeSelectorOpIn, fmt.Sprint(cores)),
+			)
+		}
 		instanceTypes = append(instanceTypes, instanceType)
 	}
 	return instanceTypes
@@ -0,0 +1,32 @@
+package cloudprovider
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+func TestInstanceTypesFromNodeClassTopology(t *testing.T) {
+	instanceType := func(cpu string) v1alpha1.InstanceType {
+		return v1alpha1.InstanceType{CPU: cpu, Memory: "8Gi", MaxPods: "110", OS: "linux", Zone: "zone-a"}
+	}
+	// the instance type topology replaces the NodeClass one
+	twoSockets := instanceType("2")
+	twoSockets.Topology = &v1alpha1.CPUTopology{CoresPerSocket: 1}
+	nodeClass := &v1alpha1.VsphereNodeClass{Spec: v1alpha1.VsphereNodeClassSpec{
+		Topology:      &v1alpha1.CPUTopology{CoresPerSocket: 4},
+		InstanceTypes: []v1alpha1.InstanceType{instanceType("8"), instanceType("6"), twoSockets},
+	}}
+
+	instanceTypes := instanceTypesFromNodeClass(nodeClass)
+	require.Len(t, instanceTypes, 2, "6 vCPUs can't be split into sockets of 4 cores")
+	assert.Equal(t, []string{"vsphere-vm.cpu-8.mem-8gb.os-linux", "vsphere-vm.cpu-2.mem-8gb.os-linux"},
+		lo.Map(instanceTypes, func(i *cloudprovider.InstanceType, _ int) string { return i.Name }))
+	assert.Equal(t, "2", instanceTypes[0].Requirements.Get(v1alpha1.LabelInstanceCPUSockets).Any())
+	assert.Equal(t, "2", instanceTypes[1].Requirements.Get(v1alpha1.LabelInstanceCPUSockets).Any())
+}
@@ -3,11 +3,13 @@ package cloudprovider
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/samber/lo"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/utils"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
 )
@@ -18,7 +20,29 @@ func (c *CloudProvider) isNodeClassDrifted(ctx context.Context, nodeClaim *karpv
 		log.FromContext(ctx).Error(err, "drifted", drifted)
 		return drifted, err
 	}
-	return "", nil
+	return c.encryptionDrifted(ctx, nodeClaim, nodeClass)
+}
+
+// encryptionDrifted compares the key provider recorded on the VM with the
+// NodeClass, so VMs are replaced even when the NodeClaim carries no hash. It is
+// read from the NodeClaim annotation set on launch, the VM is only fetched for
+// NodeClaims launched before the annotation existed.
+func (c *CloudProvider) encryptionDrifted(ctx context.Context, nodeClaim *karpv1.NodeClaim, nodeClass *v1alpha1.VsphereNodeClass) (cloudprovider.DriftReason, error) {
+	if id, ok := nodeClaim.Annotations[v1alpha1.AnnotationEncryption]; ok {
+		return lo.Ternary(id != nodeClass.Spec.Encryption.ID(), EncryptionDrift, ""), nil
+	}
+	if nodeClaim.Status.ProviderID == "" {
+		return "", nil
+	}
+	id, err := utils.ParseInstanceID(nodeClaim.Status.ProviderID)
+	if err != nil {
+		return "", fmt.Errorf("getting instance ID, %w", err)
+	}
+	i, err := c.instanceProvider.Get(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("getting instance, %w", err)
+	}
+	return lo.Ternary(i.Tags[v1alpha1.EncryptionTagKey] != nodeClass.Spec.Encryption.ID(), EncryptionDrift, ""), nil
 }
 
 func (c *CloudProvider) staticFieldsDrifted(nodeClaim *karpv1.NodeClaim, nodeClass *v1alpha1.VsphereNodeClass) cloudprovider.DriftReason {
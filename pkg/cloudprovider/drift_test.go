@@ -0,0 +1,43 @@
+package cloudprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+func TestEncryptionDrifted(t *testing.T) {
+	keyProvider := &v1alpha1.Encryption{KeyProvider: "kms"}
+	for _, tc := range []struct {
+		name       string
+		annotation string
+		encryption *v1alpha1.Encryption
+		expected   cloudprovider.DriftReason
+	}{
+		{name: "unencrypted", annotation: "", encryption: nil, expected: ""},
+		{name: "same key provider", annotation: "kms", encryption: keyProvider, expected: ""},
+		{name: "encryption added", annotation: "", encryption: keyProvider, expected: EncryptionDrift},
+		{name: "encryption removed", annotation: "kms", encryption: nil, expected: EncryptionDrift},
+		{name: "storage policy", annotation: "kms", encryption: &v1alpha1.Encryption{StoragePolicy: "encrypted"}, expected: EncryptionDrift},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// without an instance provider the VM can't be fetched
+			c := &CloudProvider{}
+			claim := &karpv1.NodeClaim{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnotationEncryption: tc.annotation}},
+				Status:     karpv1.NodeClaimStatus{ProviderID: "vsphere://42"},
+			}
+			class := &v1alpha1.VsphereNodeClass{Spec: v1alpha1.VsphereNodeClassSpec{Encryption: tc.encryption}}
+			reason, err := c.encryptionDrifted(context.Background(), claim, class)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, reason)
+		})
+	}
+}
@@ -14,7 +14,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/controllers/credentials"
 	nodeclaimgarbagecollection "github.com/absaoss/karpenter-provider-vsphere/pkg/controllers/nodeclaim/garbagecollection"
+	nodeclaimplacement "github.com/absaoss/karpenter-provider-vsphere/pkg/controllers/nodeclaim/placement"
 	nodeclasshash "github.com/absaoss/karpenter-provider-vsphere/pkg/controllers/nodeclass/hash"
 	nodeclassstatus "github.com/absaoss/karpenter-provider-vsphere/pkg/controllers/nodeclass/status"
 	nodeclasstermination "github.com/absaoss/karpenter-provider-vsphere/pkg/controllers/nodeclass/termination"
@@ -32,6 +34,7 @@ func NewControllers(
 	instanceProvider instance.Provider,
 	kubernetesVersionProvider kubernetesversion.KubernetesVersionProvider,
 	inClusterKubernetesInterface kubernetes.Interface,
+	credentialSources []credentials.Source,
 ) []controller.Controller {
 	controllers := []controller.Controller{
 		nodeclasshash.NewController(kubeClient),
@@ -39,6 +42,8 @@ func NewControllers(
 		nodeclasstermination.NewController(kubeClient, recorder),
 
 		nodeclaimgarbagecollection.NewVirtualMachine(kubeClient, cloudProvider),
+		nodeclaimplacement.NewController(kubeClient, cloudProvider),
+		credentials.NewController(inClusterKubernetesInterface, credentialSources),
 
 		status.NewController[*v1alpha1.VsphereNodeClass](kubeClient, mgr.GetEventRecorderFor("karpenter")),
 	}
@@ -0,0 +1,113 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/operator/options"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/session"
+)
+
+const (
+	UsernameKey = "username"
+	PasswordKey = "password"
+)
+
+// Source is a Secret in the system namespace holding the credentials of a vCenter session
+type Source struct {
+	SecretName string
+	Session    *session.Session
+}
+
+// Controller logs the vCenter sessions in again when their credentials Secret
+// is rotated
+type Controller struct {
+	kubeClient kubernetes.Interface
+	sources    []Source
+}
+
+func NewController(kubeClient kubernetes.Interface, sources []Source) *Controller {
+	return &Controller{
+		kubeClient: kubeClient,
+		sources:    sources,
+	}
+}
+
+// Read returns the username and password stored in the Secret
+func Read(ctx context.Context, kubeClient kubernetes.Interface, namespace, name string) (string, string, error) {
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get vCenter credentials secret %s/%s: %w", namespace, name, err)
+	}
+	username, password := string(secret.Data[UsernameKey]), string(secret.Data[PasswordKey])
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("vCenter credentials secret %s/%s requires %s and %s keys", namespace, name, UsernameKey, PasswordKey)
+	}
+	return username, password, nil
+}
+
+// Reconcile logs in again the sessions whose credentials are in the Secret of
+// the request, Secret events are the only trigger so there's nothing to poll.
+func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "vcenter.credentials")
+	var errs error
+	for _, src := range c.sources {
+		if src.SecretName != req.Name {
+			continue
+		}
+		username, password, err := Read(ctx, c.kubeClient, req.Namespace, src.SecretName)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		if src.Session.CredentialsMatch(username, password) {
+			continue
+		}
+		if err := src.Session.Login(ctx, username, password); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("failed to login with rotated credentials of secret %s: %w", src.SecretName, err))
+			continue
+		}
+		log.FromContext(ctx).WithValues("secret", src.SecretName, "username", username).Info("reloaded vCenter credentials")
+	}
+	return reconcile.Result{}, errs
+}
+
+// Register watches the Secrets of the system namespace through their own
+// informer, the manager cache would list the Secrets of every namespace.
+func (c *Controller) Register(ctx context.Context, m manager.Manager) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(c.kubeClient, 0, informers.WithNamespace(options.FromContext(ctx).SystemNamespace))
+	informer := factory.Core().V1().Secrets().Informer()
+	if err := m.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		factory.Start(ctx.Done())
+		<-ctx.Done()
+		factory.Shutdown()
+		return nil
+	})); err != nil {
+		return fmt.Errorf("failed to add the credentials secret informer: %w", err)
+	}
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("vcenter.credentials").
+		WatchesRawSource(&source.Informer{
+			Informer: informer,
+			Handler:  &handler.EnqueueRequestForObject{},
+			Predicates: []predicate.Predicate{predicate.NewPredicateFuncs(func(o client.Object) bool {
+				return lo.ContainsBy(c.sources, func(s Source) bool { return s.SecretName == o.GetName() })
+			})},
+		}).
+		Complete(c)
+}
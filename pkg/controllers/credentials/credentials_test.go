@@ -0,0 +1,81 @@
+package credentials
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/simulator"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/session"
+
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func newSession(t *testing.T) *session.Session {
+	model := simulator.VPX()
+	require.NoError(t, model.Create())
+	t.Cleanup(model.Remove)
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(server.Close)
+	s, err := session.New(context.Background(), session.Config{
+		Endpoint: server.URL.Host,
+		Username: "user",
+		Password: "pass",
+		Insecure: true,
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func TestReconcileRotatedSecret(t *testing.T) {
+	ctx := context.Background()
+	s := newSession(t)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vcenter", Namespace: "karpenter"},
+		Data:       map[string][]byte{UsernameKey: []byte("user"), PasswordKey: []byte("pass")},
+	}
+	kubeClient := fake.NewClientset(secret)
+	c := NewController(kubeClient, []Source{{SecretName: "vcenter", Session: s}})
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "karpenter", Name: "vcenter"}}
+
+	_, err := c.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, s.CredentialsMatch("user", "pass"))
+
+	secret.Data = map[string][]byte{UsernameKey: []byte("rotated"), PasswordKey: []byte("secret")}
+	_, err = kubeClient.CoreV1().Secrets("karpenter").Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// other Secrets of the namespace don't touch the session
+	_, err = c.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "karpenter", Name: "other"}})
+	require.NoError(t, err)
+	assert.True(t, s.CredentialsMatch("user", "pass"))
+
+	_, err = c.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, s.CredentialsMatch("rotated", "secret"))
+	assert.Equal(t, "rotated", s.Username())
+}
+
+func TestReconcileInvalidSecret(t *testing.T) {
+	s := newSession(t)
+	kubeClient := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vcenter", Namespace: "karpenter"},
+		Data:       map[string][]byte{UsernameKey: []byte("rotated")},
+	})
+	c := NewController(kubeClient, []Source{{SecretName: "vcenter", Session: s}})
+
+	_, err := c.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "karpenter", Name: "vcenter"}})
+	assert.Error(t, err)
+	assert.True(t, s.CredentialsMatch("user", "pass"))
+}
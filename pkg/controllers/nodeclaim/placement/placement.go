@@ -0,0 +1,105 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/awslabs/operatorpkg/singleton"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	corecloudprovider "sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+var placementLabels = []string{v1alpha1.LabelESXiHost, v1alpha1.LabelComputeCluster}
+
+// Controller keeps the ESXi host and compute cluster labels of NodeClaims and
+// their Nodes current after DRS or an administrator migrates the VMs.
+type Controller struct {
+	kubeClient    client.Client
+	cloudProvider corecloudprovider.CloudProvider
+}
+
+func NewController(kubeClient client.Client, cloudProvider corecloudprovider.CloudProvider) *Controller {
+	return &Controller{
+		kubeClient:    kubeClient,
+		cloudProvider: cloudProvider,
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context) (reconcile.Result, error) {
+	ctx = injection.WithControllerName(ctx, "nodeclaim.placement")
+	nodeClaimList := &karpv1.NodeClaimList{}
+	if err := c.kubeClient.List(ctx, nodeClaimList); err != nil {
+		return reconcile.Result{}, err
+	}
+	nodeClaims := lo.Filter(nodeClaimList.Items, func(nc karpv1.NodeClaim, _ int) bool {
+		return nc.Status.ProviderID != "" && nc.DeletionTimestamp.IsZero()
+	})
+	errs := make([]error, len(nodeClaims))
+	workqueue.ParallelizeUntil(ctx, 10, len(nodeClaims), func(i int) {
+		errs[i] = c.syncPlacement(ctx, &nodeClaims[i])
+	})
+	if err := multierr.Combine(errs...); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: time.Minute}, nil
+}
+
+func (c *Controller) syncPlacement(ctx context.Context, nodeClaim *karpv1.NodeClaim) error {
+	retrieved, err := c.cloudProvider.Get(ctx, nodeClaim.Status.ProviderID)
+	if err != nil {
+		return corecloudprovider.IgnoreNodeClaimNotFoundError(err)
+	}
+	desired := lo.PickByKeys(retrieved.Labels, placementLabels)
+	if len(desired) == 0 {
+		return nil
+	}
+	if err := patchLabels(ctx, c.kubeClient, nodeClaim, desired); err != nil {
+		return fmt.Errorf("updating nodeclaim labels, %w", err)
+	}
+	if nodeClaim.Status.NodeName == "" {
+		return nil
+	}
+	node := &v1.Node{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeClaim.Status.NodeName}, node); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if err := patchLabels(ctx, c.kubeClient, node, desired); err != nil {
+		return fmt.Errorf("updating node labels, %w", err)
+	}
+	return nil
+}
+
+func patchLabels(ctx context.Context, kubeClient client.Client, obj client.Object, desired map[string]string) error {
+	labels := obj.GetLabels()
+	if lo.EveryBy(lo.Keys(desired), func(k string) bool { return labels[k] == desired[k] }) {
+		return nil
+	}
+	stored := obj.DeepCopyObject().(client.Object)
+	obj.SetLabels(lo.Assign(labels, desired))
+	if err := kubeClient.Patch(ctx, obj, client.MergeFrom(stored)); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	log.FromContext(ctx).WithValues("name", obj.GetName(), "labels", desired).V(1).Info("updated placement labels")
+	return nil
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("nodeclaim.placement").
+		WatchesRawSource(singleton.Source()).
+		Complete(singleton.AsReconciler(c))
+}
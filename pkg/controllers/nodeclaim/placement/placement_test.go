@@ -0,0 +1,108 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	corecloudprovider "sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+// fakeCloudProvider serves the NodeClaims of the VMs by provider ID
+type fakeCloudProvider struct {
+	corecloudprovider.CloudProvider
+	vms map[string]*karpv1.NodeClaim
+}
+
+func (f *fakeCloudProvider) Get(_ context.Context, providerID string) (*karpv1.NodeClaim, error) {
+	vm, ok := f.vms[providerID]
+	if !ok {
+		return nil, corecloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("VM %s not found", providerID))
+	}
+	return vm.DeepCopy(), nil
+}
+
+func TestReconcile(t *testing.T) {
+	const providerID = "vsphere://42"
+	placed := map[string]string{v1alpha1.LabelESXiHost: "esxi-02", v1alpha1.LabelComputeCluster: "cluster-a"}
+	tests := []struct {
+		name string
+		// vm holds the labels of the VM, nil when it is gone
+		vm       map[string]string
+		labels   map[string]string
+		nodeName string
+		expected map[string]string
+	}{
+		{
+			name:     "labels are added",
+			vm:       placed,
+			nodeName: "node",
+			expected: placed,
+		},
+		{
+			name:     "migrated VM is relabelled",
+			vm:       placed,
+			labels:   map[string]string{v1alpha1.LabelESXiHost: "esxi-01", v1alpha1.LabelComputeCluster: "cluster-a", "team": "a"},
+			nodeName: "node",
+			expected: map[string]string{v1alpha1.LabelESXiHost: "esxi-02", v1alpha1.LabelComputeCluster: "cluster-a", "team": "a"},
+		},
+		{
+			name:     "unknown placement keeps the labels",
+			vm:       map[string]string{karpv1.NodePoolLabelKey: "default"},
+			labels:   map[string]string{v1alpha1.LabelESXiHost: "esxi-01"},
+			nodeName: "node",
+			expected: map[string]string{v1alpha1.LabelESXiHost: "esxi-01"},
+		},
+		{
+			name:     "missing VM is ignored",
+			labels:   map[string]string{v1alpha1.LabelESXiHost: "esxi-01"},
+			nodeName: "node",
+			expected: map[string]string{v1alpha1.LabelESXiHost: "esxi-01"},
+		},
+		{
+			name:     "NodeClaim without node",
+			vm:       placed,
+			expected: placed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			nodeClaim := &karpv1.NodeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "nodeclaim", Labels: tt.labels},
+				Status:     karpv1.NodeClaimStatus{ProviderID: providerID, NodeName: tt.nodeName},
+			}
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: tt.labels}}
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(nodeClaim, node).Build()
+			cloudProvider := &fakeCloudProvider{vms: map[string]*karpv1.NodeClaim{}}
+			if tt.vm != nil {
+				cloudProvider.vms[providerID] = &karpv1.NodeClaim{
+					ObjectMeta: metav1.ObjectMeta{Labels: tt.vm},
+					Status:     karpv1.NodeClaimStatus{ProviderID: providerID},
+				}
+			}
+
+			_, err := NewController(kubeClient, cloudProvider).Reconcile(ctx)
+			require.NoError(t, err)
+			require.NoError(t, kubeClient.Get(ctx, client.ObjectKeyFromObject(nodeClaim), nodeClaim))
+			assert.Equal(t, tt.expected, nodeClaim.Labels)
+			require.NoError(t, kubeClient.Get(ctx, types.NamespacedName{Name: "node"}, node))
+			if tt.nodeName == "" {
+				assert.Equal(t, tt.labels, node.Labels, "the node isn't touched")
+			} else {
+				assert.Equal(t, tt.expected, node.Labels)
+			}
+		})
+	}
+}
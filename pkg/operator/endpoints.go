@@ -0,0 +1,72 @@
+package operator
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// VCenterEndpoint is an additional vCenter managed by the controller,
+// VsphereNodeClasses select it by name
+type VCenterEndpoint struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// CredentialsSecret is a Secret in the system namespace with username and
+	// password keys, used instead of Username and Password and reloaded on change
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	Insecure          bool   `json:"insecure,omitempty"`
+	Datacenter        string `json:"datacenter"`
+	// Folder defaults to the vsphere-path flag
+	Folder string `json:"folder,omitempty"`
+	// CAFile is a PEM bundle of the CAs that sign the vCenter certificate
+	CAFile string `json:"caFile,omitempty"`
+	// CASecret is a Secret in the system namespace with the CA bundle in ca.crt
+	CASecret string `json:"caSecret,omitempty"`
+	// Thumbprint is the SHA-256 thumbprint the vCenter certificate must match
+	Thumbprint string `json:"thumbprint,omitempty"`
+	// CertFile and KeyFile are a solution user certificate that logs in
+	// through the vCenter STS instead of a username and password
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// TokenFile is a pre-issued SAML token used instead of a username and password
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// LoadVCenterEndpoints reads the additional vCenter endpoints from the YAML
+// file at path, no endpoints are returned when path is empty
+func LoadVCenterEndpoints(path string) ([]VCenterEndpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vCenter endpoints config: %w", err)
+	}
+	var endpoints []VCenterEndpoint
+	if err := yaml.UnmarshalStrict(data, &endpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse vCenter endpoints config: %w", err)
+	}
+	names := map[string]bool{}
+	for _, e := range endpoints {
+		if e.Name == "" || e.Endpoint == "" || e.Datacenter == "" {
+			return nil, fmt.Errorf("vCenter endpoints require name, endpoint and datacenter")
+		}
+		if e.Insecure && (e.CAFile != "" || e.CASecret != "" || e.Thumbprint != "") {
+			return nil, fmt.Errorf("vCenter endpoint %s can't combine insecure with caFile, caSecret or thumbprint", e.Name)
+		}
+		if (e.CertFile == "") != (e.KeyFile == "") {
+			return nil, fmt.Errorf("vCenter endpoint %s requires certFile and keyFile together", e.Name)
+		}
+		if (e.CertFile != "" || e.TokenFile != "") && e.CredentialsSecret != "" {
+			return nil, fmt.Errorf("vCenter endpoint %s can't combine credentialsSecret with certFile or tokenFile", e.Name)
+		}
+		if names[e.Name] {
+			return nil, fmt.Errorf("duplicate vCenter endpoint %s", e.Name)
+		}
+		names[e.Name] = true
+	}
+	return endpoints, nil
+}
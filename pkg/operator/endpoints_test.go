@@ -0,0 +1,54 @@
+package operator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadVCenterEndpoints(t *testing.T) {
+	endpoints, err := LoadVCenterEndpoints("")
+	assert.NoError(t, err)
+	assert.Empty(t, endpoints)
+
+	path := filepath.Join(t.TempDir(), "endpoints.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+- name: site-b
+  endpoint: vcenter-b.example.com
+  username: karpenter
+  password: secret
+  datacenter: dc-b
+`), 0o600))
+	endpoints, err = LoadVCenterEndpoints(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []VCenterEndpoint{{
+		Name:       "site-b",
+		Endpoint:   "vcenter-b.example.com",
+		Username:   "karpenter",
+		Password:   "secret",
+		Datacenter: "dc-b",
+	}}, endpoints)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`
+- name: site-b
+  endpoint: vcenter-b.example.com
+  datacenter: dc-b
+- name: site-b
+  endpoint: vcenter-c.example.com
+  datacenter: dc-c
+`), 0o600))
+	_, err = LoadVCenterEndpoints(path)
+	assert.ErrorContains(t, err, "duplicate")
+
+	assert.NoError(t, os.WriteFile(path, []byte(`
+- name: site-b
+  endpoint: vcenter-b.example.com
+  datacenter: dc-b
+  insecure: true
+  caSecret: vcenter-b-ca
+`), 0o600))
+	_, err = LoadVCenterEndpoints(path)
+	assert.ErrorContains(t, err, "insecure")
+}
@@ -2,33 +2,33 @@ package operator
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis"
 	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/find"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
-	x "net/url"
-
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/controllers/credentials"
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/operator/options"
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/finder"
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/instance"
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/kubernetesversion"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/session"
 
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
-	"github.com/vmware/govmomi"
-	"github.com/vmware/govmomi/session"
-	"github.com/vmware/govmomi/vapi/rest"
 	"github.com/vmware/govmomi/vapi/tags"
-	"github.com/vmware/govmomi/vim25"
-	"github.com/vmware/govmomi/vim25/soap"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/operator"
 )
 
+// CABundleKey is the key of the PEM bundle in vCenter CA Secrets
+const CABundleKey = "ca.crt"
+
 func init() {
 	karpv1.RestrictedLabelDomains = karpv1.RestrictedLabelDomains.Insert(apis.Group)
 }
@@ -39,13 +39,12 @@ type Operator struct {
 	KubernetesVersionProvider    kubernetesversion.KubernetesVersionProvider
 	InstanceProvider             instance.Provider
 	FinderProvider               *finder.Provider
+	// CredentialSources are the vCenter sessions whose credentials are read from Secrets
+	CredentialSources []credentials.Source
 }
 
 func NewOperator(ctx context.Context, operator *operator.Operator) (context.Context, *Operator) {
-	vsphereClient, restClient, err := GetVsphereClient(ctx)
-	lo.Must0(err, "creating vsphere client")
-	tagClient := tags.NewManager(restClient)
-
+	opts := options.FromContext(ctx)
 	//inClusterConfig := lo.Must(rest.InClusterConfig())
 	// for testing purposes load local kubeconfig if available
 	inClusterConfig := config.GetConfigOrDie()
@@ -56,54 +55,114 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		cache.New(15*time.Minute, 1*time.Minute),
 	)
 
-	folder := options.FromContext(ctx).VsphereFolder
-	clusterName := options.FromContext(ctx).ClusterName
-	dcName := options.FromContext(ctx).VsphereDC
-	findClient := find.NewFinder(vsphereClient, true)
+	defaultEndpoint := VCenterEndpoint{
+		Name:              opts.VsphereEndpoint,
+		Endpoint:          opts.VsphereEndpoint,
+		Username:          opts.VsphereUsername,
+		Password:          opts.VspherePassword,
+		Insecure:          opts.VsphereInsecure,
+		CAFile:            opts.VsphereCAFile,
+		CASecret:          opts.VsphereCASecret,
+		Thumbprint:        opts.VsphereThumbprint,
+		CertFile:          opts.VsphereCertFile,
+		KeyFile:           opts.VsphereKeyFile,
+		TokenFile:         opts.VsphereTokenFile,
+		Datacenter:        opts.VsphereDC,
+		Folder:            opts.VsphereFolder,
+		CredentialsSecret: opts.VsphereCredentialsSecret,
+	}
+	var credentialSources []credentials.Source
+	finderProvider, vsphereSession, err := newFinderProvider(ctx, inClusterClient, defaultEndpoint)
 	lo.Must0(err, "creating vsphere finder")
-	dc, err := findClient.Datacenter(ctx, dcName)
-	lo.Must0(err, "finding datacenter")
+	lo.Must0(operator.AddReadyzCheck("vcenter-"+defaultEndpoint.Name, vsphereSession.Check))
+	if defaultEndpoint.CredentialsSecret != "" {
+		credentialSources = append(credentialSources, credentials.Source{SecretName: defaultEndpoint.CredentialsSecret, Session: vsphereSession})
+	}
+	defaultProvider := instance.NewDefaultProvider(inClusterClient, finderProvider, opts.ClusterName)
+	defaultProvider.VCenter = defaultEndpoint.Name
+	defaultProvider.Tasks = instance.NewTaskLimiter(defaultEndpoint.Endpoint, opts.VsphereMaxConcurrentTasks)
+	lo.Must0(operator.Add(defaultProvider.Cache))
 
-	finderProvider := finder.NewDefaultProvider(tagClient, vsphereClient, findClient, dc, folder, clusterName)
-	instanceProvider := instance.NewDefaultProvider(
-		inClusterClient,
-		finderProvider,
-		options.FromContext(ctx).ClusterName,
-	)
+	endpoints, err := LoadVCenterEndpoints(opts.VsphereEndpointsConfig)
+	lo.Must0(err, "loading vCenter endpoints")
+	providers := map[string]*instance.DefaultProvider{}
+	for _, endpoint := range endpoints {
+		if endpoint.Folder == "" {
+			endpoint.Folder = opts.VsphereFolder
+		}
+		endpointFinder, endpointSession, err := newFinderProvider(ctx, inClusterClient, endpoint)
+		lo.Must0(err, fmt.Sprintf("creating vsphere finder for %s", endpoint.Name))
+		lo.Must0(operator.AddReadyzCheck("vcenter-"+endpoint.Name, endpointSession.Check))
+		if endpoint.CredentialsSecret != "" {
+			credentialSources = append(credentialSources, credentials.Source{SecretName: endpoint.CredentialsSecret, Session: endpointSession})
+		}
+		providers[endpoint.Name] = instance.NewDefaultProvider(inClusterClient, endpointFinder, opts.ClusterName)
+		providers[endpoint.Name].VCenter = endpoint.Name
+		providers[endpoint.Name].Tasks = instance.NewTaskLimiter(endpoint.Endpoint, opts.VsphereMaxConcurrentTasks)
+		lo.Must0(operator.Add(providers[endpoint.Name].Cache))
+	}
 	return ctx, &Operator{
 		Operator:                     operator,
 		KubernetesVersionProvider:    kubernetesVersionProvider,
 		InClusterKubernetesInterface: inClusterClient,
-		InstanceProvider:             instanceProvider,
+		InstanceProvider:             instance.NewRouter(defaultProvider, providers),
 		FinderProvider:               finderProvider,
+		CredentialSources:            credentialSources,
 	}
 }
 
-func GetVsphereClient(ctx context.Context) (*vim25.Client, *rest.Client, error) {
-	url := &x.URL{
-		Scheme: "https",
-		Host:   options.FromContext(ctx).VsphereEndpoint,
-		Path:   "/sdk",
+func newFinderProvider(ctx context.Context, kubeClient kubernetes.Interface, endpoint VCenterEndpoint) (*finder.Provider, *session.Session, error) {
+	username, password := endpoint.Username, endpoint.Password
+	if endpoint.CredentialsSecret != "" {
+		var err error
+		username, password, err = credentials.Read(ctx, kubeClient, options.FromContext(ctx).SystemNamespace, endpoint.CredentialsSecret)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
-	soapClient := soap.NewClient(url, options.FromContext(ctx).VsphereInsecure)
-	url.User = x.UserPassword(options.FromContext(ctx).VsphereUsername, options.FromContext(ctx).VspherePassword)
-	vimClient, err := vim25.NewClient(ctx, soapClient)
+	var caData []byte
+	if endpoint.CASecret != "" {
+		var err error
+		caData, err = readCABundle(ctx, kubeClient, options.FromContext(ctx).SystemNamespace, endpoint.CASecret)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	vsphereSession, err := session.New(ctx, session.Config{
+		Endpoint:   endpoint.Endpoint,
+		Username:   username,
+		Password:   password,
+		Insecure:   endpoint.Insecure,
+		CAFile:     endpoint.CAFile,
+		CAData:     caData,
+		Thumbprint: endpoint.Thumbprint,
+		CertFile:   endpoint.CertFile,
+		KeyFile:    endpoint.KeyFile,
+		TokenFile:  endpoint.TokenFile,
+		Limits: session.Limits{
+			QPS:   options.FromContext(ctx).VsphereClientQPS,
+			Burst: options.FromContext(ctx).VsphereClientBurst,
+		},
+	})
 	if err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to create vsphere client")
+		return nil, nil, err
 	}
-	vimClient.UserAgent = "karpenter-vsphere"
-
-	c := &govmomi.Client{
-		Client:         vimClient,
-		SessionManager: session.NewManager(vimClient),
+	findClient := find.NewFinder(vsphereSession.Client, true)
+	dc, err := findClient.Datacenter(ctx, endpoint.Datacenter)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to find datacenter %s", endpoint.Datacenter)
 	}
-	restClient := rest.NewClient(c.Client)
-	if err := c.Login(ctx, url.User); err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to create client: failed to login")
+	return finder.NewDefaultProvider(tags.NewManager(vsphereSession.REST), vsphereSession.Client, findClient, dc, endpoint.Folder, options.FromContext(ctx).ClusterName), vsphereSession, nil
+}
+
+// readCABundle returns the PEM bundle stored in the ca.crt key of the Secret
+func readCABundle(ctx context.Context, kubeClient kubernetes.Interface, namespace, name string) ([]byte, error) {
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vCenter CA secret %s/%s: %w", namespace, name, err)
 	}
-	if err := restClient.Login(ctx, url.User); err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to create client: failed to login to rest client")
+	if len(secret.Data[CABundleKey]) == 0 {
+		return nil, fmt.Errorf("vCenter CA secret %s/%s requires a %s key", namespace, name, CABundleKey)
 	}
-
-	return c.Client, restClient, nil
+	return secret.Data[CABundleKey], nil
 }
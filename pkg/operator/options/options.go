@@ -23,11 +23,33 @@ type Options struct {
 	VsphereEndpoint string
 	VsphereUsername string
 	VspherePassword string
-	VsphereFolder   string
-	VsphereDC       string
-	VsphereInsecure bool
-	KubeDistro      string
-	KubeVersion     string
+	// VsphereCredentialsSecret is the Secret holding the vCenter username and password
+	VsphereCredentialsSecret string
+	VsphereFolder            string
+	VsphereDC                string
+	VsphereInsecure          bool
+	KubeDistro               string
+	KubeVersion              string
+	SystemNamespace          string
+	// VsphereEndpointsConfig is the path of a YAML list of additional vCenters
+	VsphereEndpointsConfig string
+	// VsphereCAFile is a PEM bundle of the CAs that sign the vCenter certificate
+	VsphereCAFile string
+	// VsphereCASecret is a Secret in the system namespace with the CA bundle in ca.crt
+	VsphereCASecret string
+	// VsphereThumbprint is the SHA-256 thumbprint the vCenter certificate must match
+	VsphereThumbprint string
+	// VsphereCertFile and VsphereKeyFile are a solution user certificate that
+	// logs in through the vCenter STS instead of a username and password
+	VsphereCertFile string
+	VsphereKeyFile  string
+	// VsphereTokenFile is a pre-issued SAML token used instead of a username and password
+	VsphereTokenFile string
+	// VsphereClientQPS and VsphereClientBurst rate limit the calls to each vCenter
+	VsphereClientQPS   int
+	VsphereClientBurst int
+	// VsphereMaxConcurrentTasks caps the long-running tasks running in each vCenter
+	VsphereMaxConcurrentTasks int
 }
 
 type optionsKey struct{}
@@ -40,9 +62,21 @@ func (o *Options) AddFlags(fs *coreoptions.FlagSet) {
 	fs.StringVar(&o.VsphereEndpoint, "vsphere-endpoint", env.WithDefaultString("GOVC_URL", ""), "[REQUIRED] The vSphere endpoint to use for the vSphere provider")
 	fs.StringVar(&o.VsphereUsername, "vsphere-username", env.WithDefaultString("GOVC_USERNAME", ""), "[REQUIRED] The vSphere username to use for the vSphere provider")
 	fs.StringVar(&o.VspherePassword, "vsphere-password", env.WithDefaultString("GOVC_PASSWORD", ""), "[REQUIRED] The vSphere password to use for the vSphere provider")
+	fs.StringVar(&o.VsphereCredentialsSecret, "vsphere-credentials-secret", env.WithDefaultString("VSPHERE_CREDENTIALS_SECRET", ""), "Secret in the system namespace with the vSphere username and password keys, used instead of --vsphere-username and --vsphere-password and reloaded when rotated")
 	fs.StringVar(&o.VsphereFolder, "vsphere-path", env.WithDefaultString("VSPHERE_FOLDER", ""), "[REQUIRED] The vSphere path to use for the vSphere provider")
-	fs.StringVar(&o.VsphereDC, "vsphere-dc", env.WithDefaultString("VSPHERE_DC", ""), "[REQUIRED] The vSphere DC to use for the vSphere provider")
+	fs.StringVar(&o.VsphereDC, "vsphere-dc", env.WithDefaultString("VSPHERE_DC", ""), "[REQUIRED] The default vSphere DC, NodeClasses can select another one with dcSelector")
+	fs.StringVar(&o.SystemNamespace, "system-namespace", env.WithDefaultString("SYSTEM_NAMESPACE", "kube-system"), "The namespace the controller runs in, Secrets referenced by VsphereNodeClasses are read from it")
+	fs.StringVar(&o.VsphereEndpointsConfig, "vsphere-endpoints-config", env.WithDefaultString("VSPHERE_ENDPOINTS_CONFIG", ""), "Path of a YAML list of additional vCenter endpoints, VsphereNodeClasses select one by name with spec.vcenter")
 	fs.BoolVar(&o.VsphereInsecure, "vsphere-insecure", env.WithDefaultBool("GOVC_INSECURE", false), "[REQUIRED] The vSphere insecure flag to use for the vSphere provider")
+	fs.StringVar(&o.VsphereCAFile, "vsphere-ca-file", env.WithDefaultString("VSPHERE_CA_FILE", ""), "Path of a PEM bundle of the CAs that sign the vCenter certificate")
+	fs.StringVar(&o.VsphereCASecret, "vsphere-ca-secret", env.WithDefaultString("VSPHERE_CA_SECRET", ""), "Secret in the system namespace with a PEM bundle of the CAs that sign the vCenter certificate in the ca.crt key")
+	fs.StringVar(&o.VsphereThumbprint, "vsphere-thumbprint", env.WithDefaultString("VSPHERE_THUMBPRINT", ""), "SHA-256 thumbprint the vCenter certificate must match")
+	fs.StringVar(&o.VsphereCertFile, "vsphere-cert-file", env.WithDefaultString("VSPHERE_CERT_FILE", ""), "Path of a solution user certificate that logs in with a token from the vCenter STS instead of --vsphere-username and --vsphere-password")
+	fs.StringVar(&o.VsphereKeyFile, "vsphere-key-file", env.WithDefaultString("VSPHERE_KEY_FILE", ""), "Path of the private key of --vsphere-cert-file")
+	fs.IntVar(&o.VsphereClientQPS, "vsphere-client-qps", env.WithDefaultInt("VSPHERE_CLIENT_QPS", 20), "The smoothed rate of qps to each vCenter, 0 disables rate limiting")
+	fs.IntVar(&o.VsphereClientBurst, "vsphere-client-burst", env.WithDefaultInt("VSPHERE_CLIENT_BURST", 40), "The maximum allowed burst of queries to each vCenter")
+	fs.IntVar(&o.VsphereMaxConcurrentTasks, "vsphere-max-concurrent-tasks", env.WithDefaultInt("VSPHERE_MAX_CONCURRENT_TASKS", 20), "The maximum number of long-running tasks, e.g. clone, power and destroy, running at once in each vCenter, 0 disables the cap")
+	fs.StringVar(&o.VsphereTokenFile, "vsphere-token-file", env.WithDefaultString("VSPHERE_TOKEN_FILE", ""), "Path of a pre-issued SAML token used instead of --vsphere-username and --vsphere-password, signed with --vsphere-cert-file when it is a holder-of-key token")
 }
 
 func (o *Options) ToContext(ctx context.Context) context.Context {
@@ -76,8 +110,12 @@ func (o *Options) Parse(fs *coreoptions.FlagSet, args ...string) error {
 	return nil
 }
 
+// String returns the options as JSON with the secrets redacted
 func (o *Options) String() string {
-	opts, err := json.Marshal(o)
+	redacted := *o
+	redacted.VspherePassword = redact(o.VspherePassword)
+	redacted.JoinToken = redact(o.JoinToken)
+	opts, err := json.Marshal(redacted)
 	if err != nil {
 		return "couldn't marshal options JSON"
 	}
@@ -85,10 +123,29 @@ func (o *Options) String() string {
 	return string(opts)
 }
 
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
 func (o *Options) Validate() error {
 	if o.ClusterEndpoint == "" {
 		return fmt.Errorf("--cluster-endpoint is required")
 	}
+	if o.VsphereInsecure && (o.VsphereCAFile != "" || o.VsphereCASecret != "" || o.VsphereThumbprint != "") {
+		return errors.New("--vsphere-insecure disables certificate verification, it can't be combined with --vsphere-ca-file, --vsphere-ca-secret or --vsphere-thumbprint")
+	}
+	if (o.VsphereCertFile == "") != (o.VsphereKeyFile == "") {
+		return errors.New("--vsphere-cert-file and --vsphere-key-file must be set together")
+	}
+	if (o.VsphereCertFile != "" || o.VsphereTokenFile != "") && o.VsphereCredentialsSecret != "" {
+		return errors.New("--vsphere-credentials-secret can't be combined with --vsphere-cert-file or --vsphere-token-file")
+	}
+	if o.VsphereClientQPS < 0 || o.VsphereClientBurst < 0 || o.VsphereMaxConcurrentTasks < 0 {
+		return errors.New("--vsphere-client-qps, --vsphere-client-burst and --vsphere-max-concurrent-tasks can't be negative")
+	}
 	if o.KubeDistro == "rke2" && o.KubeVersion == "" {
 		return errors.New("--kube-distro option requires --kube-version")
 	}
@@ -0,0 +1,43 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringRedactsSecrets(t *testing.T) {
+	opts := &Options{
+		VsphereUsername: "karpenter",
+		VspherePassword: "hunter2",
+		JoinToken:       "K10abc::server:def",
+	}
+	s := opts.String()
+	assert.Contains(t, s, "karpenter")
+	assert.NotContains(t, s, "hunter2")
+	assert.NotContains(t, s, "K10abc")
+	assert.Equal(t, "hunter2", opts.VspherePassword)
+}
+
+func TestValidateInsecureWithVerification(t *testing.T) {
+	opts := &Options{
+		ClusterEndpoint:   "https://10.0.0.1:6443",
+		VsphereInsecure:   true,
+		VsphereThumbprint: "AB:CD",
+	}
+	assert.ErrorContains(t, opts.Validate(), "--vsphere-insecure")
+	opts.VsphereInsecure = false
+	assert.NoError(t, opts.Validate())
+}
+
+func TestValidateTokenAuth(t *testing.T) {
+	opts := &Options{
+		ClusterEndpoint: "https://10.0.0.1:6443",
+		VsphereCertFile: "/etc/vcenter/tls.crt",
+	}
+	assert.ErrorContains(t, opts.Validate(), "--vsphere-key-file")
+	opts.VsphereKeyFile = "/etc/vcenter/tls.key"
+	assert.NoError(t, opts.Validate())
+	opts.VsphereCredentialsSecret = "vcenter-credentials"
+	assert.ErrorContains(t, opts.Validate(), "--vsphere-credentials-secret")
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
-	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25/mo"
 )
@@ -43,6 +42,17 @@ func (p *Provider) ResolveNetwork(ctx context.Context, selector v1alpha1.Network
 	return nil, fmt.Errorf("failed to resolve network")
 }
 
+func (p *Provider) ResolveHosts(ctx context.Context, selector v1alpha1.HostSelectorTerm) ([]*object.HostSystem, error) {
+	if len(selector.Tags) > 0 {
+		return p.HostsByTag(ctx, selector.Tags)
+	}
+
+	if len(selector.Names) > 0 {
+		return p.HostsByName(ctx, selector.Names)
+	}
+	return nil, fmt.Errorf("failed to resolve hosts")
+}
+
 func (p *Provider) ResolveImage(ctx context.Context, selector v1alpha1.ImageSelectorTerm) (*object.VirtualMachine, error) {
 	if len(selector.Tags) > 0 {
 		return p.ImageByTag(ctx, selector.Tags)
@@ -69,18 +79,3 @@ func (p *Provider) isTemplate(ctx context.Context, obj *object.VirtualMachine) b
 func (p *Provider) GetFolder(ctx context.Context, f string) (*object.Folder, error) {
 	return p.FindClient.Folder(ctx, fmt.Sprintf("vm/%s", f))
 }
-
-func (p *Provider) ListVMs(ctx context.Context) ([]*object.VirtualMachine, error) {
-	folder, err := p.GetFolder(ctx, p.Folder)
-	if err != nil {
-		return nil, err
-	}
-	vmPattern := fmt.Sprintf("%s/%s*", folder.InventoryPath, p.ClusterName)
-	vms, err := p.FindClient.VirtualMachineList(ctx, vmPattern)
-	if err != nil {
-		if _, ok := err.(*find.NotFoundError); !ok {
-			return nil, err
-		}
-	}
-	return vms, nil
-}
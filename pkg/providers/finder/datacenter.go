@@ -0,0 +1,84 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/samber/lo"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+// datacenters holds a finder per datacenter, shared by all of them
+type datacenters struct {
+	mu    sync.Mutex
+	root  *Provider
+	byRef map[string]*Provider
+}
+
+// ForDatacenter returns the finder bound to the datacenter selected by name or
+// tags, the default datacenter when the selector is empty.
+func (p *Provider) ForDatacenter(ctx context.Context, selector v1alpha1.DCSelectorTerm) (*Provider, error) {
+	var dc *object.Datacenter
+	switch {
+	case len(selector.Tags) > 0:
+		tagSets, err := p.resolveTags(ctx, selector.Tags)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.taggedObjects(ctx, tagSets, "Datacenter")
+		if err != nil {
+			return nil, err
+		}
+		dc = object.NewDatacenter(p.Client, refs[0])
+		// the finder of the datacenter resolves relative paths from it
+		if dc.InventoryPath, err = find.InventoryPath(ctx, p.Client, refs[0]); err != nil {
+			return nil, fmt.Errorf("failed to get datacenter path: %w", err)
+		}
+	case selector.Name != "":
+		var err error
+		dc, err = p.FindClient.Datacenter(ctx, selector.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find datacenter %s: %w", selector.Name, err)
+		}
+	default:
+		return p.datacenters.root, nil
+	}
+	return p.datacenters.get(dc, p), nil
+}
+
+// Datacenters returns the finders of every datacenter of the vCenter,
+// including those nested in folders
+func (p *Provider) Datacenters(ctx context.Context) ([]*Provider, error) {
+	dcs, err := p.FindClient.DatacenterList(ctx, "/...")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list datacenters: %w", err)
+	}
+	return lo.Map(dcs, func(dc *object.Datacenter, _ int) *Provider { return p.datacenters.get(dc, p) }), nil
+}
+
+func (d *datacenters) get(dc *object.Datacenter, base *Provider) *Provider {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if dcProvider, ok := d.byRef[dc.Reference().Value]; ok {
+		return dcProvider
+	}
+	findClient := find.NewFinder(base.Client, true)
+	findClient.SetDatacenter(dc)
+	dcProvider := &Provider{
+		TagManager:  base.TagManager,
+		Client:      base.Client,
+		IndexClient: base.IndexClient,
+		DC:          dc,
+		FindClient:  findClient,
+		Folder:      base.Folder,
+		ClusterName: base.ClusterName,
+		datacenters: d,
+		tagCache:    base.tagCache,
+	}
+	d.byRef[dc.Reference().Value] = dcProvider
+	return dcProvider
+}
@@ -0,0 +1,102 @@
+package finder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+// nestedDatacenters is a vCenter with a datacenter at the root, /DC0, and
+// one in a folder, /F0/DC1
+func nestedDatacenters() *simulator.Model {
+	model := simulator.VPX()
+	model.Datacenter = 2
+	model.Folder = 1
+	return model
+}
+
+func newNestedTestProvider(ctx context.Context, t *testing.T, c *vim25.Client) *Provider {
+	restClient := rest.NewClient(c)
+	require.NoError(t, restClient.Login(ctx, simulator.DefaultLogin))
+	findClient := find.NewFinder(c, true)
+	dc, err := findClient.Datacenter(ctx, "/DC0")
+	require.NoError(t, err)
+	return NewDefaultProvider(tags.NewManager(restClient), c, findClient, dc, "karpenter", "DC0_H0")
+}
+
+func TestForDatacenter(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newNestedTestProvider(ctx, t, c)
+		dc1, err := find.NewFinder(c, true).Datacenter(ctx, "/F0/DC1")
+		require.NoError(t, err)
+		require.NoError(t, p.TagInstance(ctx, dc1.Reference(), map[string]string{"region": "eu"}))
+
+		tests := []struct {
+			name     string
+			selector v1alpha1.DCSelectorTerm
+			expected string
+			errors   bool
+		}{
+			{name: "default datacenter", expected: "/DC0"},
+			{name: "nested datacenter by path", selector: v1alpha1.DCSelectorTerm{Name: "/F0/DC1"}, expected: "/F0/DC1"},
+			{name: "nested datacenter by tags", selector: v1alpha1.DCSelectorTerm{Tags: map[string]string{"region": "eu"}}, expected: "/F0/DC1"},
+			{name: "unknown datacenter", selector: v1alpha1.DCSelectorTerm{Name: "/F0/DC2"}, errors: true},
+			{name: "unknown tag", selector: v1alpha1.DCSelectorTerm{Tags: map[string]string{"region": "us"}}, errors: true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				dcProvider, err := p.ForDatacenter(ctx, tt.selector)
+				if tt.errors {
+					assert.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, dcProvider.DC.InventoryPath)
+				// relative paths are resolved in the selected datacenter
+				_, err = dcProvider.FindClient.Datastore(ctx, "LocalDS_0")
+				assert.NoError(t, err)
+				again, err := p.ForDatacenter(ctx, tt.selector)
+				require.NoError(t, err)
+				assert.Same(t, dcProvider, again, "finders are shared per datacenter")
+			})
+		}
+	}, nestedDatacenters())
+}
+
+func TestVMFoldersNestedDatacenters(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newNestedTestProvider(ctx, t, c)
+		folders, err := p.VMFolders(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, folders, "nothing was created yet")
+
+		var paths []string
+		for _, dcPath := range []string{"/DC0", "/F0/DC1"} {
+			dc, err := p.FindClient.Datacenter(ctx, dcPath)
+			require.NoError(t, err)
+			dcFolders, err := dc.Folders(ctx)
+			require.NoError(t, err)
+			_, err = dcFolders.VmFolder.CreateFolder(ctx, "karpenter")
+			require.NoError(t, err)
+			paths = append(paths, dcPath+"/vm/karpenter")
+		}
+		folders, err = p.VMFolders(ctx)
+		require.NoError(t, err)
+		var found []string
+		for _, folder := range folders {
+			found = append(found, folder.InventoryPath)
+		}
+		assert.ElementsMatch(t, paths, found)
+	}, nestedDatacenters())
+}
@@ -0,0 +1,89 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ListVMProperties returns the VMs of the cluster in the folder of every
+// datacenter with the properties, retrieved with a single property collector
+// request per folder instead of per VM
+func (p *Provider) ListVMProperties(ctx context.Context, props []string) ([]mo.VirtualMachine, error) {
+	folders, err := p.VMFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manager := view.NewManager(p.Client)
+	var vms []mo.VirtualMachine
+	for _, folder := range folders {
+		containerView, err := manager.CreateContainerView(ctx, folder.Reference(), []string{"VirtualMachine"}, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create view of folder %s: %w", folder.InventoryPath, err)
+		}
+		var folderVMs []mo.VirtualMachine
+		err = containerView.Retrieve(ctx, []string{"VirtualMachine"}, append([]string{"name"}, props...), &folderVMs)
+		_ = containerView.Destroy(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve VMs of folder %s: %w", folder.InventoryPath, err)
+		}
+		for _, vm := range folderVMs {
+			if strings.HasPrefix(vm.Name, p.ClusterName) {
+				vms = append(vms, vm)
+			}
+		}
+	}
+	return vms, nil
+}
+
+// VMFolders returns the folder of the cluster VMs in every datacenter
+func (p *Provider) VMFolders(ctx context.Context) ([]*object.Folder, error) {
+	dcProviders, err := p.Datacenters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var folders []*object.Folder
+	for _, dcProvider := range dcProviders {
+		folder, err := dcProvider.FindClient.Folder(ctx, path.Join(dcProvider.DC.InventoryPath, "vm", p.Folder))
+		if err != nil {
+			if _, ok := err.(*find.NotFoundError); ok {
+				continue
+			}
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	return folders, nil
+}
+
+// TagsFromVMs returns the tags of every VM like TagsFromVM, the associations
+// are listed with one batch request and the tags are resolved from the cache
+func (t *Provider) TagsFromVMs(ctx context.Context, refs []types.ManagedObjectReference) (map[types.ManagedObjectReference]map[string]string, error) {
+	result := make(map[types.ManagedObjectReference]map[string]string, len(refs))
+	if len(refs) == 0 {
+		return result, nil
+	}
+	objects := make([]mo.Reference, len(refs))
+	for i := range refs {
+		objects[i] = refs[i]
+	}
+	attached, err := t.TagManager.ListAttachedTagsOnObjects(ctx, objects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of VMs: %w", err)
+	}
+	for _, obj := range attached {
+		vmTags, err := t.extractTagInfo(ctx, obj.TagIDs)
+		if err != nil {
+			return nil, err
+		}
+		result[obj.ObjectID.Reference()] = vmTags
+	}
+	return result, nil
+}
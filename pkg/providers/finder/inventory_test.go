@@ -0,0 +1,69 @@
+package finder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func TestListVMPropertiesAndTags(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		restClient := rest.NewClient(c)
+		require.NoError(t, restClient.Login(ctx, simulator.DefaultLogin))
+		findClient := find.NewFinder(c, true)
+		dc, err := findClient.Datacenter(ctx, "DC0")
+		require.NoError(t, err)
+		p := NewDefaultProvider(tags.NewManager(restClient), c, findClient, dc, "karpenter", "DC0_H0")
+
+		folders, err := dc.Folders(ctx)
+		require.NoError(t, err)
+		folder, err := folders.VmFolder.CreateFolder(ctx, "karpenter")
+		require.NoError(t, err)
+		vms, err := findClient.VirtualMachineList(ctx, "*")
+		require.NoError(t, err)
+		refs := make([]types.ManagedObjectReference, len(vms))
+		for i := range vms {
+			refs[i] = vms[i].Reference()
+		}
+		task, err := folder.MoveInto(ctx, refs)
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+
+		listed, err := p.ListVMProperties(ctx, []string{"config.uuid"})
+		require.NoError(t, err)
+		names := make([]string, len(listed))
+		for i, vm := range listed {
+			names[i] = vm.Name
+			assert.NotEmpty(t, vm.Config.Uuid)
+		}
+		assert.ElementsMatch(t, []string{"DC0_H0_VM0", "DC0_H0_VM1"}, names)
+
+		cluster, zone := createTag(ctx, t, p.TagManager, "karpenter.sh/clustername", "DC0_H0"), createTag(ctx, t, p.TagManager, "k8s-zone", "zone-a")
+		require.NoError(t, p.TagManager.AttachMultipleTagsToObject(ctx, []string{cluster, zone}, listed[0].Self))
+		require.NoError(t, p.TagManager.AttachTag(ctx, cluster, listed[1].Self))
+
+		vmTags, err := p.TagsFromVMs(ctx, []types.ManagedObjectReference{listed[0].Self, listed[1].Self})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"karpenter.sh/clustername": "DC0_H0", corev1.LabelTopologyZone: "zone-a"}, vmTags[listed[0].Self])
+		assert.Equal(t, map[string]string{"karpenter.sh/clustername": "DC0_H0"}, vmTags[listed[1].Self])
+	})
+}
+
+func createTag(ctx context.Context, t *testing.T, m *tags.Manager, category, name string) string {
+	categoryID, err := m.CreateCategory(ctx, &tags.Category{Name: category, Cardinality: "MULTIPLE", AssociableTypes: []string{"VirtualMachine"}})
+	require.NoError(t, err)
+	tagID, err := m.CreateTag(ctx, &tags.Tag{Name: name, CategoryID: categoryID})
+	require.NoError(t, err)
+	return tagID
+}
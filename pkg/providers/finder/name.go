@@ -3,14 +3,32 @@ package finder
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/vmware/govmomi/object"
 	corecloudprovider "sigs.k8s.io/karpenter/pkg/cloudprovider"
 )
 
+// PoolByName returns the root resource pool of the named cluster, or the
+// resource pool or vApp at the inventory path when name contains a slash.
+// Relative paths start at the datacenter, the finder would resolve them from
+// its host folder.
 func (p *Provider) PoolByName(ctx context.Context, name string) (*object.ResourcePool, error) {
-
-	poolPath := fmt.Sprintf("host/%s/Resources", name)
+	if strings.Contains(name, "/") {
+		if !path.IsAbs(name) {
+			name = path.Join(p.DC.InventoryPath, name)
+		}
+		pools, err := p.FindClient.ResourcePoolListAll(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(pools) > 1 {
+			return nil, fmt.Errorf("multiple resource pools match %s", name)
+		}
+		return pools[0], nil
+	}
+	poolPath := path.Join(p.DC.InventoryPath, "host", name, "Resources")
 	pool, err := p.FindClient.ResourcePool(ctx, poolPath)
 	return pool, err
 }
@@ -24,6 +42,19 @@ func (p *Provider) NetworkByName(ctx context.Context, name string) (*object.Netw
 	return &network, err
 }
 
+// HostsByName returns the ESXi hosts with the given names, in a cluster or standalone
+func (p *Provider) HostsByName(ctx context.Context, names []string) ([]*object.HostSystem, error) {
+	var hosts []*object.HostSystem
+	for _, name := range names {
+		found, err := p.FindClient.HostSystemList(ctx, fmt.Sprintf("*/%s", name))
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, found...)
+	}
+	return hosts, nil
+}
+
 func (p *Provider) VMByName(ctx context.Context, name string) (*object.VirtualMachine, error) {
 	return p.FindClient.VirtualMachine(ctx, name)
 }
@@ -43,6 +74,13 @@ func (p *Provider) GetVMByID(ctx context.Context, id string) (*object.VirtualMac
 	if err != nil {
 		return nil, err
 	}
+	if vmRef == nil {
+		// VMs of NodeClasses with a dcSelector live in other datacenters
+		vmRef, err = p.IndexClient.FindByUuid(ctx, nil, id, true, &ptrBool)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if vmRef == nil {
 		return nil, corecloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("vmRef not found"))
 	}
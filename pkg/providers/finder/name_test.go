@@ -0,0 +1,58 @@
+package finder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// newPools creates the pool team-a/prod and the vApp app in the root resource
+// pool of the cluster DC0_C0
+func newPools(ctx context.Context, t *testing.T, p *Provider) (root, prod *object.ResourcePool, app *object.VirtualApp) {
+	root, err := p.FindClient.ResourcePool(ctx, "/DC0/host/DC0_C0/Resources")
+	require.NoError(t, err)
+	teamA, err := root.Create(ctx, "team-a", types.DefaultResourceConfigSpec())
+	require.NoError(t, err)
+	prod, err = teamA.Create(ctx, "prod", types.DefaultResourceConfigSpec())
+	require.NoError(t, err)
+	folders, err := p.DC.Folders(ctx)
+	require.NoError(t, err)
+	app, err = root.CreateVApp(ctx, "app", types.DefaultResourceConfigSpec(), types.VAppConfigSpec{}, folders.VmFolder)
+	require.NoError(t, err)
+	return root, prod, app
+}
+
+func TestPoolByName(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTagTestProvider(ctx, t, c)
+		root, prod, app := newPools(ctx, t, p)
+
+		tests := []struct {
+			name     string
+			selector string
+			expected types.ManagedObjectReference
+		}{
+			{name: "bare cluster name", selector: "DC0_C0", expected: root.Reference()},
+			{name: "nested pool path", selector: "host/DC0_C0/Resources/team-a/prod", expected: prod.Reference()},
+			{name: "absolute nested pool path", selector: "/DC0/host/DC0_C0/Resources/team-a/prod", expected: prod.Reference()},
+			{name: "vApp path", selector: "host/DC0_C0/Resources/app", expected: app.Reference()},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				pool, err := p.PoolByName(ctx, tt.selector)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, pool.Reference())
+			})
+		}
+		_, err := p.PoolByName(ctx, "host/DC0_C0/Resources/team-b")
+		assert.Error(t, err)
+		_, err = p.PoolByName(ctx, "DC0_C1")
+		assert.Error(t, err)
+	})
+}
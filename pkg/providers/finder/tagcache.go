@@ -0,0 +1,219 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/vapi/tags"
+)
+
+// TagCacheTTL is how long tags and categories read from vCenter are reused
+const TagCacheTTL = 10 * time.Minute
+
+type cached[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// tagCache holds the tags and categories of a vCenter by ID and the listings
+// used to find them by name, shared by the finders of all datacenters
+type tagCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	now func() time.Time
+	// createMu serialises creating categories and tags, so concurrent launches
+	// don't race to create the same one
+	createMu     sync.Mutex
+	categories   map[string]cached[tags.Category]
+	categoryList cached[[]tags.Category]
+	tags         map[string]cached[tags.Tag]
+	categoryTags map[string]cached[[]tags.Tag]
+}
+
+func newTagCache(ttl time.Duration) *tagCache {
+	c := &tagCache{ttl: ttl, now: time.Now}
+	c.reset()
+	return c
+}
+
+// reset drops everything cached, e.g. after a cached tag was deleted in vCenter
+func (c *tagCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.categories = map[string]cached[tags.Category]{}
+	c.categoryList = cached[[]tags.Category]{}
+	c.tags = map[string]cached[tags.Tag]{}
+	c.categoryTags = map[string]cached[[]tags.Tag]{}
+}
+
+func lookup[T any](c *tagCache, m map[string]cached[T], key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := m[key]
+	if !ok || c.now().After(entry.expires) {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *tagCache) storeCategories(categories ...tags.Category) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	for _, category := range categories {
+		c.categories[category.ID] = cached[tags.Category]{value: category, expires: expires}
+	}
+}
+
+func (c *tagCache) storeTags(list ...tags.Tag) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	for _, tag := range list {
+		c.tags[tag.ID] = cached[tags.Tag]{value: tag, expires: expires}
+	}
+}
+
+// invalidateCategories makes the next lookup by name list the categories again
+func (c *tagCache) invalidateCategories() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.categoryList = cached[[]tags.Category]{}
+}
+
+// invalidateTags makes the next lookup by name list the tags of the category again
+func (c *tagCache) invalidateTags(categoryID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.categoryTags, categoryID)
+}
+
+// getCategory returns the category with the ID
+func (t *Provider) getCategory(ctx context.Context, id string) (*tags.Category, error) {
+	if category, ok := lookup(t.tagCache, t.tagCache.categories, id); ok {
+		return &category, nil
+	}
+	category, err := t.TagManager.GetCategory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	t.tagCache.storeCategories(*category)
+	return category, nil
+}
+
+// getTag returns the tag with the ID
+func (t *Provider) getTag(ctx context.Context, id string) (*tags.Tag, error) {
+	if tag, ok := lookup(t.tagCache, t.tagCache.tags, id); ok {
+		return &tag, nil
+	}
+	tag, err := t.TagManager.GetTag(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	t.tagCache.storeTags(*tag)
+	return tag, nil
+}
+
+// listCategories returns all the categories of the vCenter
+func (t *Provider) listCategories(ctx context.Context) ([]tags.Category, error) {
+	c := t.tagCache
+	c.mu.Lock()
+	list := c.categoryList
+	c.mu.Unlock()
+	if list.value != nil && !c.now().After(list.expires) {
+		return list.value, nil
+	}
+	categories, err := t.TagManager.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if categories == nil {
+		categories = []tags.Category{}
+	}
+	c.storeCategories(categories...)
+	c.mu.Lock()
+	c.categoryList = cached[[]tags.Category]{value: categories, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return categories, nil
+}
+
+// listCategoryTags returns all the tags of the category
+func (t *Provider) listCategoryTags(ctx context.Context, categoryID string) ([]tags.Tag, error) {
+	if list, ok := lookup(t.tagCache, t.tagCache.categoryTags, categoryID); ok {
+		return list, nil
+	}
+	list, err := t.TagManager.GetTagsForCategory(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []tags.Tag{}
+	}
+	t.tagCache.storeTags(list...)
+	c := t.tagCache
+	c.mu.Lock()
+	c.categoryTags[categoryID] = cached[[]tags.Tag]{value: list, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return list, nil
+}
+
+// categoryByName returns the ID of the category, empty when it doesn't exist
+func (t *Provider) categoryByName(ctx context.Context, name string) (string, error) {
+	categories, err := t.listCategories(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tag categories: %w", err)
+	}
+	for _, category := range categories {
+		if category.Name == name {
+			return category.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// tagByName returns the ID of the tag in the category, empty when it doesn't exist
+func (t *Provider) tagByName(ctx context.Context, name, categoryID string) (string, error) {
+	list, err := t.listCategoryTags(ctx, categoryID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags of category %s: %w", categoryID, err)
+	}
+	for _, tag := range list {
+		if tag.Name == name {
+			return tag.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// isAlreadyExists reports whether vCenter refused to create a tag or category
+// because another client created it first
+func isAlreadyExists(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "already_exists")
+}
+
+// getOrCreate returns the ID found by find, creating it when missing. The
+// creation is serialised, and when another client created it first in the
+// meantime it is found again.
+func (t *Provider) getOrCreate(find, create func() (string, error), invalidate func()) (string, error) {
+	if id, err := find(); err != nil || id != "" {
+		return id, err
+	}
+	t.tagCache.createMu.Lock()
+	defer t.tagCache.createMu.Unlock()
+	// another launch may have created it while this one waited
+	if id, err := find(); err != nil || id != "" {
+		return id, err
+	}
+	id, err := create()
+	invalidate()
+	if isAlreadyExists(err) {
+		if id, findErr := find(); findErr != nil || id != "" {
+			return id, findErr
+		}
+	}
+	return id, err
+}
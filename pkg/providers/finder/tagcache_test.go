@@ -0,0 +1,106 @@
+package finder
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
+
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func newTagTestProvider(ctx context.Context, t *testing.T, c *vim25.Client) *Provider {
+	restClient := rest.NewClient(c)
+	require.NoError(t, restClient.Login(ctx, simulator.DefaultLogin))
+	findClient := find.NewFinder(c, true)
+	dc, err := findClient.Datacenter(ctx, "DC0")
+	require.NoError(t, err)
+	return NewDefaultProvider(tags.NewManager(restClient), c, findClient, dc, "karpenter", "DC0_H0")
+}
+
+func TestCreateOrUpdateTagsConcurrently(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTagTestProvider(ctx, t, c)
+		instanceTags := map[string]string{"karpenter.sh/nodepool": "default", "topology.kubernetes.io/zone": "zone-a"}
+
+		results := make([][]string, 8)
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ids, err := p.CreateOrUpdateTags(ctx, instanceTags)
+				assert.NoError(t, err)
+				results[i] = ids
+			}()
+		}
+		wg.Wait()
+		for _, ids := range results {
+			assert.ElementsMatch(t, results[0], ids)
+		}
+		categories, err := p.TagManager.GetCategories(ctx)
+		require.NoError(t, err)
+		assert.Len(t, categories, 2)
+	})
+}
+
+func TestCreateOrUpdateCategoryCreatedElsewhere(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p, other := newTagTestProvider(ctx, t, c), newTagTestProvider(ctx, t, c)
+		id, err := p.categoryByName(ctx, "karpenter.sh/nodepool")
+		require.NoError(t, err)
+		require.Empty(t, id)
+
+		// the listing cached by p misses the category another controller creates
+		created, err := other.CreateOrUpdateCategory(ctx, "karpenter.sh/nodepool")
+		require.NoError(t, err)
+		id, err = p.CreateOrUpdateCategory(ctx, "karpenter.sh/nodepool")
+		require.NoError(t, err)
+		assert.Equal(t, created, id)
+
+		tagID, err := other.GetOrCreateTag(ctx, "default", created)
+		require.NoError(t, err)
+		_, err = p.tagByName(ctx, "other", created)
+		require.NoError(t, err)
+		id, err = p.GetOrCreateTag(ctx, "default", created)
+		require.NoError(t, err)
+		assert.Equal(t, tagID, id)
+	})
+}
+
+func TestTagCacheTTL(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTagTestProvider(ctx, t, c)
+		now := time.Now()
+		p.tagCache.now = func() time.Time { return now }
+		categoryID, err := p.CreateOrUpdateCategory(ctx, "k8s-zone")
+		require.NoError(t, err)
+		tagID, err := p.GetOrCreateTag(ctx, "zone-a", categoryID)
+		require.NoError(t, err)
+
+		vmTags, err := p.extractTagInfo(ctx, []string{tagID})
+		require.NoError(t, err)
+		assert.Equal(t, "zone-a", vmTags["topology.kubernetes.io/zone"])
+
+		tag, err := p.TagManager.GetTag(ctx, tagID)
+		require.NoError(t, err)
+		tag.Name = "zone-b"
+		require.NoError(t, p.TagManager.UpdateTag(ctx, tag))
+		vmTags, err = p.extractTagInfo(ctx, []string{tagID})
+		require.NoError(t, err)
+		assert.Equal(t, "zone-a", vmTags["topology.kubernetes.io/zone"], "served from the cache")
+
+		now = now.Add(TagCacheTTL + time.Second)
+		vmTags, err = p.extractTagInfo(ctx, []string{tagID})
+		require.NoError(t, err)
+		assert.Equal(t, "zone-b", vmTags["topology.kubernetes.io/zone"], "read again once expired")
+	})
+}
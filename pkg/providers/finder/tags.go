@@ -1,63 +1,157 @@
 package finder
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/samber/lo"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-func (t *Provider) getObjectByType(ctx context.Context, tags []*tags.Tag, objT string) (*types.ManagedObjectReference, error) {
-	objTagCount := map[types.ManagedObjectReference]int{}
-	for _, tag := range tags {
-		objs, err := t.TagManager.ListAttachedObjects(ctx, tag.ID)
+// taggedObjects returns every object of the kinds carrying at least one tag
+// of each set, ordered by name so the same object is picked on every launch.
+func (t *Provider) taggedObjects(ctx context.Context, tagSets [][]tags.Tag, kinds ...string) ([]types.ManagedObjectReference, error) {
+	var attached [][]types.ManagedObjectReference
+	for _, set := range tagSets {
+		ids := lo.Map(set, func(tag tags.Tag, _ int) string { return tag.ID })
+		objs, err := t.TagManager.ListAttachedObjectsOnTags(ctx, ids)
 		if err != nil {
 			return nil, err
 		}
+		var refs []types.ManagedObjectReference
 		for _, obj := range objs {
-			if obj.Reference().Type == objT {
-				objTagCount[obj.Reference()]++
+			for _, ref := range obj.ObjectIDs {
+				if slices.Contains(kinds, ref.Reference().Type) {
+					refs = append(refs, ref.Reference())
+				}
 			}
 		}
+		attached = append(attached, refs)
 	}
-	for obj, count := range objTagCount {
-		if count == len(tags) {
-			return &obj, nil
+	matches := matchingAll(attached)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no %s objects matching all of %d tags", strings.Join(kinds, ", "), len(tagSets))
+	}
+	return t.sortByName(ctx, matches)
+}
+
+// matchingAll returns the objects present in every list
+func matchingAll(lists [][]types.ManagedObjectReference) []types.ManagedObjectReference {
+	count := map[types.ManagedObjectReference]int{}
+	for _, list := range lists {
+		for _, ref := range lo.Uniq(list) {
+			count[ref]++
+		}
+	}
+	var matches []types.ManagedObjectReference
+	for ref, c := range count {
+		if c == len(lists) {
+			matches = append(matches, ref)
 		}
 	}
-	return nil, fmt.Errorf("no %s objects matching tag count %d", objT, len(tags))
+	return matches
 }
 
-func (t *Provider) getObjectByTag(ctx context.Context, taglist map[string]string, typeName string) (object.Reference, error) {
-	var vsphereTags []*tags.Tag
-	for k, v := range taglist {
-		tag, err := t.getTagID(ctx, k, v)
-		if err != nil {
-			return nil, err
+// sortByName orders the objects by name, objects of the same name by type and ID
+func (t *Provider) sortByName(ctx context.Context, refs []types.ManagedObjectReference) ([]types.ManagedObjectReference, error) {
+	var content []types.ObjectContent
+	if err := property.DefaultCollector(t.Client).Retrieve(ctx, refs, []string{"name"}, &content); err != nil {
+		return nil, fmt.Errorf("failed to get object names: %w", err)
+	}
+	names := map[types.ManagedObjectReference]string{}
+	for _, c := range content {
+		for _, prop := range c.PropSet {
+			if name, ok := prop.Val.(string); ok && prop.Name == "name" {
+				names[c.Obj] = name
+			}
 		}
-		vsphereTags = append(vsphereTags, tag)
 	}
-	obj, err := t.getObjectByType(ctx, vsphereTags, typeName)
+	sorted := slices.Clone(refs)
+	slices.SortFunc(sorted, func(a, b types.ManagedObjectReference) int {
+		return cmp.Or(strings.Compare(names[a], names[b]), strings.Compare(a.Type, b.Type), strings.Compare(a.Value, b.Value))
+	})
+	return sorted, nil
+}
+
+// ofKind returns the objects of the kind
+func ofKind(refs []types.ManagedObjectReference, kind string) []types.ManagedObjectReference {
+	return lo.Filter(refs, func(ref types.ManagedObjectReference, _ int) bool { return ref.Type == kind })
+}
+
+// resolveTags returns the tags matching each key/value of the selector. Keys
+// match every category of that name, a value of * matches all tags of the
+// category and a value ending in * matches tags by prefix.
+func (t *Provider) resolveTags(ctx context.Context, taglist map[string]string) ([][]tags.Tag, error) {
+	categories, err := t.listCategories(ctx)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list tag categories: %w", err)
+	}
+	var tagSets [][]tags.Tag
+	for k, v := range taglist {
+		var matched []tags.Tag
+		for _, category := range categories {
+			if category.Name != k {
+				continue
+			}
+			categoryTags, err := t.listCategoryTags(ctx, category.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list tags of category %s: %w", k, err)
+			}
+			matched = append(matched, lo.Filter(categoryTags, func(tag tags.Tag, _ int) bool {
+				return matchTagValue(v, tag.Name)
+			})...)
+		}
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("no tag matching %s=%s", k, v)
+		}
+		tagSets = append(tagSets, matched)
 	}
-	return object.NewReference(t.Client, obj.Reference()), nil
+	return tagSets, nil
 }
 
+func matchTagValue(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return pattern == name
+}
+
+// PoolByTag returns the root resource pool of the tagged cluster, or the
+// tagged resource pool or vApp when no cluster carries the tags
 func (t *Provider) PoolByTag(ctx context.Context, tag map[string]string) (*object.ResourcePool, error) {
-	ref, err := t.getObjectByTag(ctx, tag, "ClusterComputeResource")
+	vsphereTags, err := t.resolveTags(ctx, tag)
 	if err != nil {
 		return nil, err
 	}
-	if ref == nil {
-		return nil, fmt.Errorf("failed to get Pool by Tag return is %v", ref)
+	kinds := []string{"ClusterComputeResource", "ResourcePool", "VirtualApp"}
+	refs, err := t.taggedObjects(ctx, vsphereTags, kinds...)
+	if err != nil {
+		return nil, fmt.Errorf("no cluster, resource pool or vApp matching tags %v: %w", tag, err)
+	}
+	for _, kind := range kinds {
+		matches := ofKind(refs, kind)
+		if len(matches) == 0 {
+			continue
+		}
+		switch obj := object.NewReference(t.Client, matches[0]).(type) {
+		case *object.ClusterComputeResource:
+			return GetRootResourcePool(ctx, obj)
+		case *object.ResourcePool:
+			return obj, nil
+		case *object.VirtualApp:
+			return obj.ResourcePool, nil
+		}
 	}
-	refObj := ref.(*object.ClusterComputeResource)
-	return GetRootResourcePool(ctx, refObj)
+	return nil, fmt.Errorf("no cluster, resource pool or vApp matching tags %v", tag)
 }
 
 func GetRootResourcePool(ctx context.Context, cluster *object.ClusterComputeResource) (*object.ResourcePool, error) {
@@ -66,63 +160,98 @@ func GetRootResourcePool(ctx context.Context, cluster *object.ClusterComputeReso
 }
 
 func (t *Provider) NetworkByTag(ctx context.Context, tags map[string]string) (*object.NetworkReference, error) {
-	kinds := []string{"Network", "DistributedVirtualSwitch", "DistributedVirtualPortgroup", "OpaqueNetwork"}
-	var networkRefs []object.NetworkReference
-
-	for _, k := range kinds {
-		ref, err := t.getObjectByTag(ctx, tags, k)
-		if err != nil {
-			continue
-		}
-		networkRefs = append(networkRefs, ref.(object.NetworkReference))
+	tagSets, err := t.resolveTags(ctx, tags)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(networkRefs) == 0 {
-		return nil, fmt.Errorf("failed to network by Tag")
-	} else if len(networkRefs) > 1 {
+	refs, err := t.taggedObjects(ctx, tagSets, "Network", "DistributedVirtualSwitch", "DistributedVirtualPortgroup", "OpaqueNetwork")
+	if err != nil {
+		return nil, fmt.Errorf("failed to network by Tag: %w", err)
+	}
+	if len(refs) > 1 {
 		return nil, fmt.Errorf("multiple networks found")
 	}
-
-	return &networkRefs[0], nil
+	networkRef := object.NewReference(t.Client, refs[0]).(object.NetworkReference)
+	return &networkRef, nil
 }
 
+// DatastoreByTag returns the accessible tagged datastore with the most free space
 func (t *Provider) DatastoreByTag(ctx context.Context, tag map[string]string) (*object.Datastore, error) {
-	ref, err := t.getObjectByTag(ctx, tag, "Datastore")
-	if ref == nil {
-		return nil, fmt.Errorf("failed to get Datastore by Tag return is %v", ref)
+	tagSets, err := t.resolveTags(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := t.taggedObjects(ctx, tagSets, "Datastore")
+	if err != nil {
+		return nil, err
+	}
+	var datastores []mo.Datastore
+	err = property.DefaultCollector(t.Client).Retrieve(ctx, refs, []string{"summary.accessible", "summary.freeSpace"}, &datastores)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get datastore properties: %w", err)
+	}
+	datastores = lo.Filter(datastores, func(ds mo.Datastore, _ int) bool { return ds.Summary.Accessible })
+	if len(datastores) == 0 {
+		return nil, fmt.Errorf("no accessible datastore matching tags %v", tag)
 	}
-	return ref.(*object.Datastore), err
+	ds := lo.MaxBy(datastores, func(a, b mo.Datastore) bool { return a.Summary.FreeSpace > b.Summary.FreeSpace })
+	return object.NewDatastore(t.Client, ds.Reference()), nil
 }
 
+// ImageByTag returns the tagged template first by name
 func (t *Provider) ImageByTag(ctx context.Context, tag map[string]string) (*object.VirtualMachine, error) {
-	ref, err := t.getObjectByTag(ctx, tag, "VirtualMachine")
+	tagSets, err := t.resolveTags(ctx, tag)
 	if err != nil {
 		return nil, err
 	}
-	if ref == nil {
-		return nil, fmt.Errorf("failed to get Template by Tag return is %v", ref)
+	refs, err := t.taggedObjects(ctx, tagSets, "VirtualMachine")
+	if err != nil {
+		return nil, err
 	}
-	vm := ref.(*object.VirtualMachine)
-	if !t.isTemplate(ctx, vm) {
-		return nil, fmt.Errorf("failed to find VirtualMachine template")
+	for _, ref := range refs {
+		vm := object.NewVirtualMachine(t.Client, ref)
+		if t.isTemplate(ctx, vm) {
+			return vm, nil
+		}
 	}
-	return vm, nil
+	return nil, fmt.Errorf("failed to find VirtualMachine template")
 }
 
-func (t *Provider) getTagID(ctx context.Context, k, v string) (*tags.Tag, error) {
-	return t.TagManager.GetTagForCategory(ctx, v, k)
+func (t *Provider) HostsByTag(ctx context.Context, taglist map[string]string) ([]*object.HostSystem, error) {
+	vsphereTags, err := t.resolveTags(ctx, taglist)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := t.taggedObjects(ctx, vsphereTags, "HostSystem")
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]*object.HostSystem, 0, len(refs))
+	for _, ref := range refs {
+		hosts = append(hosts, object.NewHostSystem(t.Client, ref))
+	}
+	return hosts, nil
 }
 
+// TagInstance attaches the tags to the VM in one batch call and reads them
+// back, as the batch may attach only some of them.
 func (t *Provider) TagInstance(ctx context.Context, obj types.ManagedObjectReference, tags map[string]string) error {
 	tagIDs, err := t.CreateOrUpdateTags(ctx, tags)
 	if err != nil {
 		return err
 	}
-	for _, tagID := range tagIDs {
-		err = t.TagManager.AttachTag(ctx, tagID, obj)
-		if err != nil {
-			return err
-		}
+	if err := t.TagManager.AttachMultipleTagsToObject(ctx, tagIDs, obj); err != nil {
+		// a cached tag may have been deleted in vCenter
+		t.tagCache.reset()
+		return fmt.Errorf("failed to attach tags: %w", err)
+	}
+	attached, err := t.TagManager.ListAttachedTags(ctx, obj)
+	if err != nil {
+		return fmt.Errorf("failed to read back attached tags: %w", err)
+	}
+	if missing, _ := lo.Difference(tagIDs, attached); len(missing) > 0 {
+		t.tagCache.reset()
+		return fmt.Errorf("failed to attach tags %s", strings.Join(missing, ", "))
 	}
 	return nil
 }
@@ -148,33 +277,29 @@ func (t *Provider) CreateOrUpdateTags(ctx context.Context, instanceTags map[stri
 }
 
 func (t *Provider) CreateOrUpdateCategory(ctx context.Context, name string) (string, error) {
-	vsphereCategory, err := t.TagManager.GetCategory(ctx, name)
+	id, err := t.getOrCreate(
+		func() (string, error) { return t.categoryByName(ctx, name) },
+		func() (string, error) { return t.TagManager.CreateCategory(ctx, getCategoryObject(name)) },
+		t.tagCache.invalidateCategories,
+	)
 	if err != nil {
-		fmt.Println("error getting vsphere category", err)
-		vsphereCategory, err := t.TagManager.CreateCategory(ctx, getCategoryObject(name))
-		if err != nil {
-			return "", fmt.Errorf("failed to create vsphere category %s: %w", name, err)
-		}
-		return vsphereCategory, nil
+		return "", fmt.Errorf("failed to create vsphere category %s: %w", name, err)
 	}
-	return vsphereCategory.ID, nil
+	return id, nil
 }
 
 func (t *Provider) GetOrCreateTag(ctx context.Context, name, categoryID string) (string, error) {
-	tag, err := t.TagManager.GetTagForCategory(ctx, name, categoryID)
-	if err != nil {
-		fmt.Println("error getting vsphere tag", err)
-		id, err := t.TagManager.CreateTag(ctx, &tags.Tag{
-			Description: "karpenter managed tag",
-			Name:        name,
-			CategoryID:  categoryID,
-		})
-		if err != nil {
-			return "", err
-		}
-		return id, nil
-	}
-	return tag.ID, nil
+	return t.getOrCreate(
+		func() (string, error) { return t.tagByName(ctx, name, categoryID) },
+		func() (string, error) {
+			return t.TagManager.CreateTag(ctx, &tags.Tag{
+				Description: "karpenter managed tag",
+				Name:        name,
+				CategoryID:  categoryID,
+			})
+		},
+		func() { t.tagCache.invalidateTags(categoryID) },
+	)
 }
 
 func getCategoryObject(name string) *tags.Category {
@@ -191,27 +316,31 @@ func (t *Provider) TagsFromVM(ctx context.Context, vm *object.VirtualMachine) (m
 	if err != nil {
 		log.FromContext(ctx).Error(err, fmt.Sprintf("failed to list tags for VM %s", vm.Name()))
 	}
-	return extractTagInfo(ctx, t.TagManager, tagsAttached)
+	return t.extractTagInfo(ctx, tagsAttached)
 
 }
 
-func extractTagInfo(ctx context.Context, tagManager *tags.Manager, tagIDs []string) (map[string]string, error) {
+func (t *Provider) extractTagInfo(ctx context.Context, tagIDs []string) (map[string]string, error) {
 	tags := make(map[string]string)
 	for _, tagID := range tagIDs {
-		tag, err := tagManager.GetTag(ctx, tagID)
+		tag, err := t.getTag(ctx, tagID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get tag %s: %w", tagID, err)
 		}
-		cat, err := tagManager.GetCategory(ctx, tag.CategoryID)
+		cat, err := t.getCategory(ctx, tag.CategoryID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get category for tag %s: %w", tagID, err)
 		}
-		if cat.Name == "k8s-zone" {
-			// Normalize Vsphere tag to fulfill CPI requirements
-			cat.Name = corev1.LabelTopologyZone
-		}
-		tags[cat.Name] = tag.Name
+		tags[normalizeCategory(cat.Name)] = tag.Name
 	}
 	return tags, nil
 
 }
+
+// normalizeCategory maps the vSphere zone category to the label the CPI uses
+func normalizeCategory(name string) string {
+	if name == "k8s-zone" {
+		return corev1.LabelTopologyZone
+	}
+	return name
+}
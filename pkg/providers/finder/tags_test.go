@@ -0,0 +1,122 @@
+package finder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestMatchTagValue(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{pattern: "prod", name: "prod", match: true},
+		{pattern: "prod", name: "prod-1", match: false},
+		{pattern: "*", name: "anything", match: true},
+		{pattern: "prod-*", name: "prod-1", match: true},
+		{pattern: "prod-*", name: "dev-1", match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.match, matchTagValue(tt.pattern, tt.name))
+		})
+	}
+}
+
+func TestMatchingAll(t *testing.T) {
+	ds1 := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-1"}
+	ds2 := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-2"}
+	ds3 := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-3"}
+
+	assert.ElementsMatch(t, []types.ManagedObjectReference{ds1, ds2}, matchingAll([][]types.ManagedObjectReference{
+		{ds2, ds1, ds3, ds1},
+		{ds1, ds2},
+	}))
+	assert.Empty(t, matchingAll([][]types.ManagedObjectReference{{ds1}, {ds2}}))
+}
+
+func TestTaggedObjectsByName(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTagTestProvider(ctx, t, c)
+		names := []string{"DC0_H0_VM1", "DC0_C0_RP0_VM0", "DC0_H0_VM0"}
+		for _, name := range names {
+			vm, err := p.FindClient.VirtualMachine(ctx, "/DC0/vm/"+name)
+			require.NoError(t, err)
+			require.NoError(t, p.TagInstance(ctx, vm.Reference(), map[string]string{"role": "template"}))
+		}
+		tagSets, err := p.resolveTags(ctx, map[string]string{"role": "template"})
+		require.NoError(t, err)
+		for range 3 {
+			refs, err := p.taggedObjects(ctx, tagSets, "VirtualMachine")
+			require.NoError(t, err)
+			var sorted []string
+			for _, ref := range refs {
+				vm := object.NewVirtualMachine(c, ref)
+				name, err := vm.ObjectName(ctx)
+				require.NoError(t, err)
+				sorted = append(sorted, name)
+			}
+			assert.Equal(t, []string{"DC0_C0_RP0_VM0", "DC0_H0_VM0", "DC0_H0_VM1"}, sorted)
+		}
+		_, err = p.taggedObjects(ctx, tagSets, "Datastore")
+		assert.Error(t, err)
+	})
+}
+
+func TestPoolByTag(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTagTestProvider(ctx, t, c)
+		root, prod, app := newPools(ctx, t, p)
+		cluster, err := p.FindClient.ClusterComputeResource(ctx, "/DC0/host/DC0_C0")
+		require.NoError(t, err)
+		require.NoError(t, p.TagInstance(ctx, cluster.Reference(), map[string]string{"compute": "cluster"}))
+		require.NoError(t, p.TagInstance(ctx, prod.Reference(), map[string]string{"compute": "pool"}))
+		require.NoError(t, p.TagInstance(ctx, app.Reference(), map[string]string{"compute": "vapp"}))
+
+		tests := []struct {
+			name     string
+			value    string
+			expected types.ManagedObjectReference
+		}{
+			{name: "cluster", value: "cluster", expected: root.Reference()},
+			{name: "nested pool", value: "pool", expected: prod.Reference()},
+			{name: "vApp", value: "vapp", expected: app.ResourcePool.Reference()},
+			{name: "cluster first", value: "*", expected: root.Reference()},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				pool, err := p.PoolByTag(ctx, map[string]string{"compute": tt.value})
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, pool.Reference())
+			})
+		}
+		_, err = p.PoolByTag(ctx, map[string]string{"compute": "none"})
+		assert.Error(t, err)
+	})
+}
+
+func TestTagInstance(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTagTestProvider(ctx, t, c)
+		vm, err := p.FindClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		require.NoError(t, err)
+		instanceTags := map[string]string{
+			"karpenter.sh/clustername":    "DC0_H0",
+			"karpenter.sh/nodepool":       "default",
+			"topology.kubernetes.io/zone": "zone-a",
+		}
+		require.NoError(t, p.TagInstance(ctx, vm.Reference(), instanceTags))
+
+		vmTags, err := p.TagsFromVM(ctx, vm)
+		require.NoError(t, err)
+		assert.Equal(t, instanceTags, vmTags)
+	})
+}
@@ -15,11 +15,15 @@ type Provider struct {
 	FindClient  *find.Finder
 	Folder      string
 	ClusterName string
+	datacenters *datacenters
+	tagCache    *tagCache
 }
 
 func NewDefaultProvider(tMgr *tags.Manager, client *vim25.Client, findClient *find.Finder, dc *object.Datacenter, folder, cluster string) *Provider {
 	idx := object.NewSearchIndex(client)
 	// Set Datacenter globally for find operations
 	findClient.SetDatacenter(dc)
-	return &Provider{ClusterName: cluster, TagManager: tMgr, Client: client, IndexClient: idx, Folder: folder, FindClient: findClient, DC: dc}
+	p := &Provider{ClusterName: cluster, TagManager: tMgr, Client: client, IndexClient: idx, Folder: folder, FindClient: findClient, DC: dc, tagCache: newTagCache(TagCacheTTL)}
+	p.datacenters = &datacenters{root: p, byRef: map[string]*Provider{dc.Reference().Value: p}}
+	return p
 }
@@ -0,0 +1,320 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/finder"
+	"github.com/samber/lo"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// CacheResyncInterval is how often the cache drops its property filter
+	// and lists the VMs again, picking up tags changed outside Karpenter
+	CacheResyncInterval = 10 * time.Minute
+	// CacheRetryInterval is how long the cache waits to watch again after
+	// the property filter failed, e.g. because the session was lost
+	CacheRetryInterval = 10 * time.Second
+)
+
+// Cache keeps the VMs of the cluster in memory, updated by a property filter
+// on the VM folders through WaitForUpdatesEx. Until it is synced the reads
+// report a miss and the instance provider asks vCenter directly.
+type Cache struct {
+	finder *finder.Provider
+
+	mu     sync.RWMutex
+	synced bool
+	vms    map[types.ManagedObjectReference]*mo.VirtualMachine
+	tags   map[types.ManagedObjectReference]map[string]string
+	hosts  map[types.ManagedObjectReference]placement
+	// marked are the VMs missing the cluster tag but carrying the marker
+	marked map[types.ManagedObjectReference]bool
+}
+
+// fetched is what the cache looks up besides the VM properties
+type fetched struct {
+	tags   map[types.ManagedObjectReference]map[string]string
+	hosts  map[types.ManagedObjectReference]placement
+	marked map[types.ManagedObjectReference]bool
+}
+
+func NewCache(finder *finder.Provider) *Cache {
+	return &Cache{finder: finder}
+}
+
+// Start watches the VM folders until the context is cancelled, it implements
+// manager.Runnable
+func (c *Cache) Start(ctx context.Context) error {
+	for {
+		watchCtx, cancel := context.WithTimeout(ctx, CacheResyncInterval)
+		err := c.watch(watchCtx)
+		resync := watchCtx.Err() != nil
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if resync {
+			continue
+		}
+		log.FromContext(ctx).Error(err, "failed to watch VMs, serving them from vCenter until the cache is synced again")
+		c.mu.Lock()
+		c.synced = false
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(CacheRetryInterval):
+		}
+	}
+}
+
+// watch fills the cache from a new property filter and applies its updates
+// until the context is done or a vCenter call fails
+func (c *Cache) watch(ctx context.Context) error {
+	folders, err := c.finder.VMFolders(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find VM folders: %w", err)
+	}
+	if len(folders) == 0 {
+		// nothing was created yet, look for the folders on the next resync
+		c.swap(map[types.ManagedObjectReference]*mo.VirtualMachine{}, &fetched{})
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	// the views and the collector belong to the session, they are destroyed
+	// even when the watch was cancelled
+	cleanupCtx := context.WithoutCancel(ctx)
+	collector, err := property.DefaultCollector(c.finder.Client).Create(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create property collector: %w", err)
+	}
+	defer func() { _ = collector.Destroy(cleanupCtx) }()
+	manager := view.NewManager(c.finder.Client)
+	filter := types.CreateFilter{Spec: types.PropertyFilterSpec{
+		PropSet: []types.PropertySpec{{Type: "VirtualMachine", PathSet: append([]string{"name"}, vmProperties...)}},
+	}}
+	for _, folder := range folders {
+		containerView, err := manager.CreateContainerView(ctx, folder.Reference(), []string{"VirtualMachine"}, false)
+		if err != nil {
+			return fmt.Errorf("failed to create view of folder %s: %w", folder.InventoryPath, err)
+		}
+		defer func() { _ = containerView.Destroy(cleanupCtx) }()
+		filter.Spec.ObjectSet = append(filter.Spec.ObjectSet, types.ObjectSpec{
+			Obj:       containerView.Reference(),
+			Skip:      types.NewBool(true),
+			SelectSet: []types.BaseSelectionSpec{&types.TraversalSpec{Type: "ContainerView", Path: "view"}},
+		})
+	}
+	if _, err := collector.CreateFilter(ctx, filter); err != nil {
+		return fmt.Errorf("failed to create property filter: %w", err)
+	}
+
+	// the initial content may arrive truncated over several batches, it is
+	// collected aside so the previous content is served until it is complete
+	vms := map[types.ManagedObjectReference]*mo.VirtualMachine{}
+	synced := false
+	var updateErr error
+	opts := &property.WaitOptions{}
+	err = collector.WaitForUpdatesEx(ctx, opts, func(updates []types.ObjectUpdate) bool {
+		if !synced {
+			applyUpdates(vms, updates, c.finder.ClusterName)
+			if opts.Truncated {
+				return false
+			}
+			f, fetchErr := c.fetch(ctx, vms, lo.Keys(vms), nil)
+			if fetchErr != nil {
+				updateErr = fetchErr
+				return true
+			}
+			c.swap(vms, f)
+			synced = true
+			return false
+		}
+		updateErr = c.update(ctx, updates)
+		return updateErr != nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wait for VM updates: %w", err)
+	}
+	if updateErr != nil {
+		return updateErr
+	}
+	return ctx.Err()
+}
+
+// update applies the updates to the synced cache, fetching the tags of the
+// new VMs and the placement of hosts not seen before
+func (c *Cache) update(ctx context.Context, updates []types.ObjectUpdate) error {
+	c.mu.Lock()
+	entered := applyUpdates(c.vms, updates, c.finder.ClusterName)
+	for _, u := range updates {
+		if u.Kind == types.ObjectUpdateKindLeave {
+			delete(c.tags, u.Obj)
+			delete(c.marked, u.Obj)
+		}
+	}
+	// VMs created by this provider are tagged through setTags already
+	entered = lo.Filter(entered, func(ref types.ManagedObjectReference, _ int) bool { return c.tags[ref] == nil })
+	vms := maps.Clone(c.vms)
+	known := maps.Clone(c.hosts)
+	c.mu.Unlock()
+
+	f, err := c.fetch(ctx, vms, entered, known)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ref, vmTags := range f.tags {
+		// tags recorded while the lookup was in flight are more recent
+		if _, ok := c.vms[ref]; ok && c.tags[ref] == nil {
+			c.tags[ref] = vmTags
+			c.marked[ref] = f.marked[ref]
+		}
+	}
+	maps.Copy(c.hosts, f.hosts)
+	return nil
+}
+
+// fetch returns the tags and markers of the refs and the placement of the
+// hosts running the VMs which are not known yet
+func (c *Cache) fetch(ctx context.Context, vms map[types.ManagedObjectReference]*mo.VirtualMachine, refs []types.ManagedObjectReference,
+	known map[types.ManagedObjectReference]placement) (*fetched, error) {
+	tags, err := c.finder.TagsFromVMs(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	// VMs without tags are recorded too so they are not looked up again
+	for _, ref := range refs {
+		if tags[ref] == nil {
+			tags[ref] = map[string]string{}
+		}
+	}
+	untagged := lo.Filter(refs, func(ref types.ManagedObjectReference, _ int) bool {
+		return tags[ref][v1alpha1.ClusterNameTagKey] != c.finder.ClusterName
+	})
+	marked, err := markedVMs(ctx, c.finder.Client, untagged, c.finder.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	hostRefs := lo.FilterMap(lo.Values(vms), func(vm *mo.VirtualMachine, _ int) (types.ManagedObjectReference, bool) {
+		_, ok := known[lo.FromPtr(vm.Runtime.Host)]
+		return lo.FromPtr(vm.Runtime.Host), vm.Runtime.Host != nil && !ok
+	})
+	hosts, err := hostPlacements(ctx, c.finder.Client, hostRefs)
+	if err != nil {
+		return nil, err
+	}
+	return &fetched{tags: tags, hosts: hosts, marked: marked}, nil
+}
+
+// applyUpdates applies the property changes to the VMs and returns the VMs
+// which entered the view, VMs of other clusters are ignored
+func applyUpdates(vms map[types.ManagedObjectReference]*mo.VirtualMachine, updates []types.ObjectUpdate, clusterName string) []types.ManagedObjectReference {
+	var entered []types.ManagedObjectReference
+	for _, u := range updates {
+		switch u.Kind {
+		case types.ObjectUpdateKindEnter:
+			vm := &mo.VirtualMachine{}
+			vm.Self = u.Obj
+			mo.ApplyPropertyChange(vm, u.ChangeSet)
+			if !strings.HasPrefix(vm.Name, clusterName) {
+				continue
+			}
+			vms[u.Obj] = vm
+			entered = append(entered, u.Obj)
+		case types.ObjectUpdateKindModify:
+			if vm, ok := vms[u.Obj]; ok {
+				mo.ApplyPropertyChange(vm, u.ChangeSet)
+			}
+		case types.ObjectUpdateKindLeave:
+			delete(vms, u.Obj)
+		}
+	}
+	return entered
+}
+
+func (c *Cache) swap(vms map[types.ManagedObjectReference]*mo.VirtualMachine, f *fetched) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vms = vms
+	c.tags = lo.Ternary(f.tags == nil, map[types.ManagedObjectReference]map[string]string{}, f.tags)
+	c.hosts = lo.Ternary(f.hosts == nil, map[types.ManagedObjectReference]placement{}, f.hosts)
+	c.marked = lo.Ternary(f.marked == nil, map[types.ManagedObjectReference]bool{}, f.marked)
+	c.synced = true
+}
+
+// setTags records the tags attached to a VM, the cache does not watch tags
+func (c *Cache) setTags(ref types.ManagedObjectReference, tags map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synced {
+		c.tags[ref] = maps.Clone(tags)
+	}
+}
+
+// forget drops a destroyed VM ahead of its update
+func (c *Cache) forget(ref types.ManagedObjectReference) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synced {
+		delete(c.vms, ref)
+		delete(c.tags, ref)
+		delete(c.marked, ref)
+	}
+}
+
+// Synced reports whether the cache holds every VM of the cluster
+func (c *Cache) Synced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced
+}
+
+// list returns the running VMs of the cluster, ok is false until synced
+func (c *Cache) list(clusterName string) ([]*Instance, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.synced {
+		return nil, false
+	}
+	instances := []*Instance{}
+	for ref, vm := range c.vms {
+		if !listed(*vm) || (c.tags[ref][v1alpha1.ClusterNameTagKey] != clusterName && !c.marked[ref]) {
+			continue
+		}
+		instances = append(instances, c.instance(vm))
+	}
+	return instances, true
+}
+
+// get returns the VM with the UUID, ok is false until synced or when the VM
+// is not cached
+func (c *Cache) get(uuid string) (*Instance, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.synced {
+		return nil, false
+	}
+	for _, vm := range c.vms {
+		if vm.Config != nil && vm.Config.Uuid == uuid {
+			return c.instance(vm), true
+		}
+	}
+	return nil, false
+}
+
+func (c *Cache) instance(vm *mo.VirtualMachine) *Instance {
+	return newInstanceFromProperties(c.finder.Client, *vm, maps.Clone(c.tags[vm.Self]), c.hosts[lo.FromPtr(vm.Runtime.Host)])
+}
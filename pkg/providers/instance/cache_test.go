@@ -0,0 +1,103 @@
+package instance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/finder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func TestCache(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		restClient := rest.NewClient(c)
+		require.NoError(t, restClient.Login(ctx, simulator.DefaultLogin))
+		findClient := find.NewFinder(c, true)
+		dc, err := findClient.Datacenter(ctx, "DC0")
+		require.NoError(t, err)
+		f := finder.NewDefaultProvider(tags.NewManager(restClient), c, findClient, dc, "karpenter", "DC0_H0")
+
+		folders, err := dc.Folders(ctx)
+		require.NoError(t, err)
+		folder, err := folders.VmFolder.CreateFolder(ctx, "karpenter")
+		require.NoError(t, err)
+		vm0, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		require.NoError(t, err)
+		vm1, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM1")
+		require.NoError(t, err)
+		task, err := folder.MoveInto(ctx, []types.ManagedObjectReference{vm0.Reference(), vm1.Reference()})
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		clusterTags := map[string]string{v1alpha1.ClusterNameTagKey: "DC0_H0"}
+		require.NoError(t, f.TagInstance(ctx, vm0.Reference(), clusterTags))
+		// VM1 failed to be tagged, it carries only the marker
+		task, err = vm1.Reconfigure(ctx, types.VirtualMachineConfigSpec{ExtraConfig: []types.BaseOptionValue{clusterMarker("DC0_H0")}})
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+
+		p := NewDefaultProvider(nil, f, "DC0_H0")
+		_, ok := p.Cache.list("DC0_H0")
+		assert.False(t, ok, "reads miss until the cache is synced")
+
+		cacheCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.NoError(t, p.Cache.Start(cacheCtx))
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+		require.Eventually(t, func() bool {
+			_, ok := p.Cache.list("DC0_H0")
+			return ok
+		}, 10*time.Second, 50*time.Millisecond)
+
+		instances, err := p.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 2)
+		uuid := vm0.UUID(ctx)
+		instance, ok := p.Cache.get(uuid)
+		require.True(t, ok)
+		assert.Equal(t, "DC0_H0_VM0", instance.Name)
+		assert.Equal(t, "DC0_H0", instance.Host)
+		assert.Equal(t, clusterTags, instance.Tags)
+
+		// tags recorded on create replace those looked up
+		p.Cache.setTags(vm1.Reference(), clusterTags)
+		instance, ok = p.Cache.get(vm1.UUID(ctx))
+		require.True(t, ok)
+		assert.Equal(t, clusterTags, instance.Tags)
+
+		task, err = vm1.PowerOff(ctx)
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		require.Eventually(t, func() bool {
+			instances, _ := p.Cache.list("DC0_H0")
+			return len(instances) == 1
+		}, 10*time.Second, 50*time.Millisecond, "poweredOff VMs are not listed")
+
+		task, err = vm0.PowerOff(ctx)
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		task, err = vm0.Destroy(ctx)
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		require.Eventually(t, func() bool {
+			_, ok := p.Cache.get(uuid)
+			return !ok
+		}, 10*time.Second, 50*time.Millisecond, "destroyed VMs leave the cache")
+	})
+}
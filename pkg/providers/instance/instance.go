@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"maps"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/operator/options"
@@ -17,7 +18,9 @@ import (
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/finder"
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/utils"
+	"github.com/samber/lo"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25"
 	models "github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	"k8s.io/client-go/kubernetes"
@@ -37,15 +40,49 @@ var _ Provider = (*DefaultProvider)(nil)
 
 type DefaultProvider struct {
 	ClusterName string
-	kubeClient  kubernetes.Interface
-	Finder      *finder.Provider
+	// VCenter is the name of the vCenter the provider manages, recorded as a VM tag
+	VCenter    string
+	kubeClient kubernetes.Interface
+	Finder     *finder.Provider
+	// affinityMu serialises the read-modify-write of the DRS rule VM lists
+	affinityMu *sync.Mutex
+	// Cache serves Get and List from memory once it is started and synced
+	Cache *Cache
+	// Tasks caps the clone, power and destroy tasks running in the vCenter
+	Tasks *TaskLimiter
+	// hosts serves the placement of VMs not served by the cache
+	hosts           *hostPlacementCache
+	storagePolicies *storagePolicies
 }
 
 func NewDefaultProvider(kube kubernetes.Interface, finder *finder.Provider, clusterName string) *DefaultProvider {
 	return &DefaultProvider{
-		ClusterName: clusterName,
-		kubeClient:  kube,
-		Finder:      finder,
+		ClusterName:     clusterName,
+		kubeClient:      kube,
+		Finder:          finder,
+		affinityMu:      &sync.Mutex{},
+		Cache:           NewCache(finder),
+		hosts:           newHostPlacementCache(),
+		storagePolicies: &storagePolicies{},
+	}
+}
+
+// inDatacenter returns a copy of the provider resolving inventory with the
+// finder of another datacenter
+func (p *DefaultProvider) inDatacenter(dcFinder *finder.Provider) *DefaultProvider {
+	if dcFinder == p.Finder {
+		return p
+	}
+	return &DefaultProvider{
+		ClusterName:     p.ClusterName,
+		VCenter:         p.VCenter,
+		kubeClient:      p.kubeClient,
+		Finder:          dcFinder,
+		affinityMu:      p.affinityMu,
+		Cache:           p.Cache,
+		Tasks:           p.Tasks,
+		hosts:           p.hosts,
+		storagePolicies: p.storagePolicies,
 	}
 }
 
@@ -60,30 +97,49 @@ func (p *DefaultProvider) GenerateVMSpec(ctx context.Context, class *v1alpha1.Vs
 		return nil, fmt.Errorf("failed to generate target for VM: %w", err)
 	}
 
-	diskAndNet, err := p.GetDeviceSpec(ctx, class, class.Spec.DiskSize)
+	diskAndNet, err := p.GetDeviceSpec(ctx, class, image, class.Spec.DiskSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get device spec: %w", err)
 	}
+	diskLocator, err := p.GetRootDiskLocator(ctx, class, image, *locationSpec.Datastore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get root disk placement: %w", err)
+	}
+	if diskLocator != nil {
+		locationSpec.Disk = append(locationSpec.Disk, *diskLocator)
+	}
 
 	t := time.Now()
-	return &types.VirtualMachineCloneSpec{
+	config := &types.VirtualMachineConfigSpec{
+		Flags: &types.VirtualMachineFlagInfo{
+			// disk.EnableUUID = TRUE
+			DiskUuidEnabled: &diskEnableUUID,
+		},
+		Name:         name,
+		Annotation:   fmt.Sprintf("cloned_from:%s", image.InventoryPath),
+		NumCPUs:      int32(instanceType.Capacity.Cpu().Value()),
+		MemoryMB:     instanceType.Capacity.Memory().ScaledValue(resource.Mega),
+		GuestId:      guestID(class, instanceType),
+		DeviceChange: diskAndNet,
+		CreateDate:   &t,
+	}
+	applyGuestOS(config, class.Spec.GuestOS)
+	if t, ok := class.Spec.InstanceTypeByName(instanceType.Name); ok {
+		applyAllocation(config, t)
+		if err := applyTopology(config, class.Spec.CPUTopologyFor(t)); err != nil {
+			return nil, err
+		}
+	}
+	cloneSpec := &types.VirtualMachineCloneSpec{
 		Template: false,
 		Location: *locationSpec,
-		Config: &types.VirtualMachineConfigSpec{
-			Flags: &types.VirtualMachineFlagInfo{
-				// disk.EnableUUID = TRUE
-				DiskUuidEnabled: &diskEnableUUID,
-			},
-			Name:         name,
-			Annotation:   fmt.Sprintf("cloned_from:%s", image.InventoryPath),
-			NumCPUs:      int32(instanceType.Capacity.Cpu().Value()),
-			MemoryMB:     instanceType.Capacity.Memory().ScaledValue(resource.Mega),
-			GuestId:      string(types.VirtualMachineGuestOsIdentifierOtherLinux64Guest), // This should be adjusted based on the OS type in the instance type.
-			DeviceChange: diskAndNet,
-			CreateDate:   &t,
-		},
-		PowerOn: false,
-	}, nil
+		Config:   config,
+		PowerOn:  false,
+	}
+	if err := p.applyEncryption(ctx, class.Spec.Encryption, cloneSpec); err != nil {
+		return nil, fmt.Errorf("failed to configure encryption: %w", err)
+	}
+	return cloneSpec, nil
 }
 
 func (p *DefaultProvider) GenerateTarget(ctx context.Context, class *v1alpha1.VsphereNodeClass) (*types.VirtualMachineRelocateSpec, error) {
@@ -94,6 +150,16 @@ func (p *DefaultProvider) GenerateTarget(ctx context.Context, class *v1alpha1.Vs
 	}
 	poolRef := pool.Reference()
 	relocationSpec.Pool = &poolRef
+	if class.Spec.HostSelector != nil {
+		hosts, err := p.selectedHosts(ctx, *class.Spec.HostSelector, poolRef)
+		if err != nil {
+			return nil, err
+		}
+		relocationSpec.Host, err = pickHost(hosts)
+		if err != nil {
+			return nil, err
+		}
+	}
 	datastore, err := p.Finder.ResolveDatastore(ctx, class.Spec.DatastoreSelector)
 	if err != nil {
 		return nil, err
@@ -109,6 +175,18 @@ func (p *DefaultProvider) Create(
 	class *v1alpha1.VsphereNodeClass,
 	claim *karpv1.NodeClaim,
 	instanceTypes []*corecloudprovider.InstanceType) (*Instance, error) {
+	dcFinder, err := p.Finder.ForDatacenter(ctx, class.Spec.Datacenter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve datacenter: %w", err)
+	}
+	return p.inDatacenter(dcFinder).create(ctx, class, claim, instanceTypes)
+}
+
+func (p *DefaultProvider) create(
+	ctx context.Context,
+	class *v1alpha1.VsphereNodeClass,
+	claim *karpv1.NodeClaim,
+	instanceTypes []*corecloudprovider.InstanceType) (*Instance, error) {
 
 	instanceType := instanceTypes[0] // For simplicity, we take the first instance type.
 	VMName := GenerateVMName(p.ClusterName, claim.Name)
@@ -121,6 +199,15 @@ func (p *DefaultProvider) Create(
 		v1alpha1.LabelInstanceMemory: fmt.Sprintf("%d", utils.GiToMb(instanceType.Capacity.Memory().ToDec().Value())),
 	}
 
+	if p.VCenter != "" {
+		instanceTags[v1alpha1.VCenterTagKey] = p.VCenter
+	}
+	if id := class.Spec.Encryption.ID(); id != "" {
+		instanceTags[v1alpha1.EncryptionTagKey] = id
+	}
+	if class.Spec.AntiAffinity != nil {
+		instanceTags[v1alpha1.AntiAffinityTagKey] = antiAffinityRuleName(p.ClusterName, claim.Labels[karpv1.NodePoolLabelKey])
+	}
 	maps.Copy(instanceTags, class.Spec.Tags)
 	//Default carpenter taint
 	taints := []corev1.Taint{
@@ -139,23 +226,41 @@ func (p *DefaultProvider) Create(
 	initType := &userdata.InitType{
 		Distro: v1alpha1.Distro(controllerOpts.KubeDistro),
 		Format: class.Spec.UserData.Type,
+		OS:     instanceTypeOS(instanceType),
 	}
 
 	userData, err := p.GetInitData(workerInitConfig, initType)
 	if err != nil {
 		return nil, err
 	}
-	vmTemplate, err := p.Finder.ResolveImage(ctx, class.Spec.ImageSelector)
+	arch := instanceTypeArch(instanceType)
+	vmTemplate, err := p.Finder.ResolveImage(ctx, class.Spec.ImageSelector.ForArch(arch))
 	if err != nil {
-		return nil, fmt.Errorf("failed to find VM template: %w", err)
+		return nil, fmt.Errorf("failed to find VM template for arch %s: %w", arch, err)
 	}
 
 	cloneSpec, err := p.GenerateVMSpec(ctx, class, VMName, vmTemplate, instanceType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate VM spec: %w", err)
 	}
+	extraConfig, err := mergeExtraConfig(cloneSpec.Config.ExtraConfig, class.Spec.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
 	// add Init data
-	cloneSpec.Config.ExtraConfig = userData
+	cloneSpec.Config.ExtraConfig = append(extraConfig, userData...)
+	cloneSpec.Config.ExtraConfig = append(cloneSpec.Config.ExtraConfig, clusterMarker(p.ClusterName))
+	if initType.OS == string(corev1.Windows) {
+		cloneSpec.Customization, err = p.GetWindowsCustomization(ctx, class, claim.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate windows customization: %w", err)
+		}
+	}
+	if class.Spec.DRSPlacement {
+		if err := p.placeVM(ctx, vmTemplate, VMName, cloneSpec); err != nil {
+			return nil, err
+		}
+	}
 	vmFolder, err := p.Finder.ResolveFolder(ctx)
 	if err != nil {
 		return nil, err
@@ -168,42 +273,61 @@ func (p *DefaultProvider) Create(
 		}
 	}
 
-	task, err := vmTemplate.Clone(ctx, vmFolder, VMName, *cloneSpec)
+	err = p.Tasks.run(ctx, func() (*object.Task, error) { return vmTemplate.Clone(ctx, vmFolder, VMName, *cloneSpec) })
 	if err != nil {
 		return nil, fmt.Errorf("failed to clone VM: %w", err)
 	}
 
-	err = task.Wait(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("task failed: %w", err)
-	}
-
 	vm, err = p.Finder.VMByName(ctx, VMName)
 	if err != nil {
 		return nil, err
 	}
 
-	err = p.Finder.TagInstance(ctx, vm.Reference(), instanceTags)
-	if err != nil {
+	if version := class.Spec.GuestOS.HardwareVersion; version != "" {
+		if err := upgradeHardwareVersion(ctx, vm, version); err != nil {
+			p.discardVM(ctx, vm)
+			return nil, err
+		}
+		if class.Spec.GuestOS.VTPM {
+			if err := addVTPM(ctx, vm); err != nil {
+				p.discardVM(ctx, vm)
+				return nil, err
+			}
+		}
+	}
+
+	if err := p.tagVM(ctx, vm, instanceTags); err != nil {
 		return nil, err
 	}
 
+	if class.Spec.HostSelector != nil {
+		if err := p.joinHostGroupRule(ctx, class, *cloneSpec.Location.Pool, vm.Reference()); err != nil {
+			p.discardVM(ctx, vm)
+			return nil, fmt.Errorf("failed to join host group rule: %w", err)
+		}
+	}
+	if class.Spec.AntiAffinity != nil {
+		err = p.joinAntiAffinityRule(ctx, class.Spec.AntiAffinity, *cloneSpec.Location.Pool, claim.Labels[karpv1.NodePoolLabelKey], vm.Reference())
+		if err != nil {
+			p.discardVM(ctx, vm)
+			return nil, fmt.Errorf("failed to join anti-affinity rule: %w", err)
+		}
+	}
+
 	creationDate, err := extractCreationDate(ctx, vm)
 	if err != nil {
 		return nil, err
 	}
 
-	powerOnTask, err := vm.PowerOn(ctx)
-	err = powerOnTask.Wait(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("task failed: %w", err)
+	if err := p.Tasks.run(ctx, func() (*object.Task, error) { return vm.PowerOn(ctx) }); err != nil {
+		return nil, fmt.Errorf("failed to power on VM: %w", err)
 	}
 
 	powerState, err := vm.PowerState(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get power state: %w", err)
 	}
-	return NewInstance(vm, vm.UUID(ctx), vmTemplate.InventoryPath, string(powerState), vm.Name(), *creationDate, instanceTags), err
+	return p.withPlacement(ctx, NewInstance(vm, vm.UUID(ctx), vmTemplate.InventoryPath, string(powerState), vm.Name(), *creationDate, instanceTags)), err
 }
 
 func extractCreationDate(ctx context.Context, vm *object.VirtualMachine) (*time.Time, error) {
@@ -223,68 +347,92 @@ func GenerateVMName(cluster, claim string) string {
 	return fmt.Sprintf("%s-karp-%s", cluster, claim)
 }
 
-func getImageFromAnnotation(vm *object.VirtualMachine) string {
-	annotation := models.VirtualMachine{
-		Config: &types.VirtualMachineConfigInfo{},
-	}
-	err := vm.Properties(context.Background(), vm.Reference(), []string{"config.annotation"}, &annotation)
-	if err != nil && annotation.Config.Annotation == "" {
-		annotation.Config.Annotation = "image_not_found"
-		log.Log.Info(err.Error())
-	}
-	return strings.TrimPrefix(annotation.Config.Annotation, "cloned_from:")
+// imageFromAnnotation returns the template recorded in the annotation of a clone
+func imageFromAnnotation(annotation string) string {
+	return strings.TrimPrefix(annotation, "cloned_from:")
 }
 
+// List returns the running VMs of the cluster. They are served by the cache
+// once it is synced, otherwise their properties, tags and placement are
+// retrieved in bulk so the number of calls barely grows with the number of VMs.
 func (p *DefaultProvider) List(ctx context.Context) ([]*Instance, error) {
+	if instances, ok := p.Cache.list(p.ClusterName); ok {
+		return instances, nil
+	}
 	instances := []*Instance{}
-	vms, err := p.Finder.ListVMs(ctx)
-	//
+	vms, err := p.Finder.ListVMProperties(ctx, vmProperties)
 	if err != nil {
-		log.FromContext(ctx).Error(err, "")
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
 	}
+	vms = lo.Filter(vms, func(vm models.VirtualMachine, _ int) bool { return listed(vm) })
 	if len(vms) < 1 {
 		return instances, nil
 	}
+	vmTags, err := p.Finder.TagsFromVMs(ctx, lo.Map(vms, func(vm models.VirtualMachine, _ int) types.ManagedObjectReference { return vm.Self }))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags of VMs: %w", err)
+	}
+	hostRefs := lo.FilterMap(vms, func(vm models.VirtualMachine, _ int) (types.ManagedObjectReference, bool) {
+		return lo.FromPtr(vm.Runtime.Host), vm.Runtime.Host != nil
+	})
+	placements, err := hostPlacements(ctx, p.Finder.Client, hostRefs)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to get placement of VMs")
+	}
+	// VMs whose tags failed to attach are found by their marker
+	untagged := lo.FilterMap(vms, func(vm models.VirtualMachine, _ int) (types.ManagedObjectReference, bool) {
+		return vm.Self, vmTags[vm.Self][v1alpha1.ClusterNameTagKey] != p.ClusterName
+	})
+	marked, err := markedVMs(ctx, p.Finder.Client, untagged, p.ClusterName)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to look for untagged VMs of the cluster")
+	}
 	for _, vm := range vms {
-		ps, err := vm.PowerState(ctx)
-		if err != nil {
-			log.FromContext(ctx).Error(err, fmt.Sprintf("failed to get power state for VM %s", vm.Name()))
-		}
-		// skip poweredOff machines
-		if ps == "poweredOff" {
-			continue
-		}
-		image := getImageFromAnnotation(vm)
-		tags, err := p.Finder.TagsFromVM(ctx, vm)
-		if err != nil {
-			log.FromContext(ctx).Error(err, fmt.Sprintf("failed to get tags for VM %s", vm.Name()))
-		}
-
-		creationDate, err := extractCreationDate(ctx, vm)
-		if err != nil {
-			log.FromContext(ctx).Error(err, fmt.Sprintf("failed to extract creation date for VM %s", vm.Name()))
-		}
+		tags := vmTags[vm.Self]
 		// find only VMs belonging to current cluster
-		if tags["karpneter.sh/clustername"] != p.ClusterName {
+		if tags[v1alpha1.ClusterNameTagKey] != p.ClusterName && !marked[vm.Self] {
 			continue
 		}
-		instances = append(instances, NewInstance(vm, vm.UUID(ctx), image, string(ps), vm.Name(), *creationDate, tags))
+		instances = append(instances, newInstanceFromProperties(p.Finder.Client, vm, tags, placements[lo.FromPtr(vm.Runtime.Host)]))
 	}
 	return instances, nil
 }
 
+// vmProperties are the VM properties instances are built from
+var vmProperties = []string{"config.uuid", "config.annotation", "config.createDate", "runtime.powerState", "runtime.host"}
+
+// listed reports whether List returns the VM, poweredOff machines and those
+// still being created are skipped
+func listed(vm models.VirtualMachine) bool {
+	return vm.Config != nil && vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOff
+}
+
+func newInstanceFromProperties(c *vim25.Client, vm models.VirtualMachine, tags map[string]string, placement placement) *Instance {
+	config := lo.FromPtr(vm.Config)
+	instance := NewInstance(object.NewVirtualMachine(c, vm.Self), config.Uuid, imageFromAnnotation(config.Annotation),
+		string(vm.Runtime.PowerState), vm.Name, lo.FromPtr(config.CreateDate).UTC(), tags)
+	instance.Host, instance.ComputeCluster = placement.host, placement.cluster
+	return instance
+}
+
 func (p *DefaultProvider) Get(ctx context.Context, vmID string) (*Instance, error) {
+	if instance, ok := p.Cache.get(vmID); ok {
+		return instance, nil
+	}
 	vm, err := p.Finder.GetVMByID(ctx, vmID)
 	if err != nil {
 		return nil, err
 	}
+	// the VM and its host are read in one call, the host placement is cached
+	var vmMo models.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), append([]string{"name"}, vmProperties...), &vmMo); err != nil {
+		return nil, fmt.Errorf("failed to get VM properties: %w", err)
+	}
 	tags, err := p.Finder.TagsFromVM(ctx, vm)
 	if err != nil {
-		log.FromContext(ctx).Error(err, fmt.Sprintf("failed to get tags for VM %s", vm.Name()))
+		log.FromContext(ctx).Error(err, fmt.Sprintf("failed to get tags for VM %s", vmMo.Name))
 	}
-	instance := NewInstanceFromVM(ctx, vm, time.Now(), tags)
-	return instance, nil
-
+	return newInstanceFromProperties(p.Finder.Client, vmMo, tags, p.hostPlacement(ctx, vmMo.Runtime.Host, vmMo.Name)), nil
 }
 
 func (p *DefaultProvider) Delete(ctx context.Context, vmID string) error {
@@ -293,24 +441,38 @@ func (p *DefaultProvider) Delete(ctx context.Context, vmID string) error {
 		return err
 	}
 	vm := i.GetVM()
-	task, err := vm.PowerOff(ctx)
-	if err != nil {
-		return err
-	}
-	// Wait for the power off task to complete
-	err = task.Wait(ctx)
-	if err != nil {
-		return err
+	if rule := i.Tags[v1alpha1.AntiAffinityTagKey]; rule != "" {
+		// best effort, a rule that can't be updated must not block the delete
+		if err := p.leaveAntiAffinityRule(ctx, vm, rule); err != nil {
+			log.FromContext(ctx).Error(err, fmt.Sprintf("failed to leave anti-affinity rule %s", rule))
+		}
 	}
-	task, err = vm.Destroy(ctx)
-	if err != nil {
+	if err := p.Tasks.run(ctx, func() (*object.Task, error) { return vm.PowerOff(ctx) }); err != nil {
 		return err
 	}
-	// Wait for the destroy task to complete
-	err = task.Wait(ctx)
-	if err != nil {
+	if err := p.Tasks.run(ctx, func() (*object.Task, error) { return vm.Destroy(ctx) }); err != nil {
 		return err
 	}
+	p.Cache.forget(vm.Reference())
+
+	return nil
+}
 
+// tagVM attaches the instance tags to the cloned VM. A VM that fails to be
+// tagged is destroyed, it is still powered off so List and GC skip it.
+func (p *DefaultProvider) tagVM(ctx context.Context, vm *object.VirtualMachine, tags map[string]string) error {
+	if err := p.Finder.TagInstance(ctx, vm.Reference(), tags); err != nil {
+		p.discardVM(ctx, vm)
+		return fmt.Errorf("failed to tag VM %s: %w", vm.Name(), err)
+	}
+	p.Cache.setTags(vm.Reference(), tags)
 	return nil
 }
+
+// discardVM destroys a VM which is still powered off after a failed launch,
+// failures are only logged as the launch error is returned anyway
+func (p *DefaultProvider) discardVM(ctx context.Context, vm *object.VirtualMachine) {
+	if err := p.Tasks.run(ctx, func() (*object.Task, error) { return vm.Destroy(ctx) }); err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf("failed to destroy VM %s after a failed launch", vm.Name()))
+	}
+}
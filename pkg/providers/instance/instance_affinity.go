@@ -0,0 +1,181 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/samber/lo"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+// antiAffinityRuleName is the name of the DRS rule spreading the VMs of a NodePool
+func antiAffinityRuleName(clusterName, nodePool string) string {
+	return fmt.Sprintf("%s-karp-%s-anti-affinity", clusterName, nodePool)
+}
+
+// poolCluster returns the DRS cluster owning the resource pool, nil when the
+// pool belongs to a standalone host.
+func (p *DefaultProvider) poolCluster(ctx context.Context, poolRef types.ManagedObjectReference) (*object.ClusterComputeResource, error) {
+	owner, err := object.NewResourcePool(p.Finder.Client, poolRef).Owner(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource pool owner: %w", err)
+	}
+	cluster, ok := owner.(*object.ClusterComputeResource)
+	if !ok {
+		return nil, nil
+	}
+	return cluster, nil
+}
+
+// joinAntiAffinityRule adds the VM to the anti-affinity rule of its NodePool.
+// A rule needs at least two VMs, so it is created with the VMs of the NodePool
+// launched before anti-affinity was enabled, or once the second VM joins.
+func (p *DefaultProvider) joinAntiAffinityRule(ctx context.Context, antiAffinity *v1alpha1.AntiAffinity, poolRef types.ManagedObjectReference, nodePool string, vm types.ManagedObjectReference) error {
+	cluster, err := p.poolCluster(ctx, poolRef)
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		return fmt.Errorf("anti-affinity requires a DRS cluster, resource pool %s belongs to a standalone host", poolRef.Value)
+	}
+	p.affinityMu.Lock()
+	defer p.affinityMu.Unlock()
+
+	config, err := cluster.Configuration(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster configuration: %w", err)
+	}
+	name := antiAffinityRuleName(p.ClusterName, nodePool)
+	rule := findAntiAffinityRule(config, name)
+	var members []types.ManagedObjectReference
+	if rule != nil {
+		members = rule.Vm
+	} else {
+		members, err = p.nodePoolVMs(ctx, cluster, nodePool)
+		if err != nil {
+			return err
+		}
+	}
+	mandatory := antiAffinity.Enforcement == v1alpha1.AffinityEnforcementMust
+	return reconfigureRule(ctx, cluster, antiAffinityRuleSpec(rule, name, mandatory, append(members, vm)))
+}
+
+// leaveAntiAffinityRule removes the VM from the anti-affinity rule it joined,
+// the rule is dropped once less than two VMs remain.
+func (p *DefaultProvider) leaveAntiAffinityRule(ctx context.Context, vm *object.VirtualMachine, name string) error {
+	pool, err := vm.ResourcePool(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get VM resource pool: %w", err)
+	}
+	cluster, err := p.poolCluster(ctx, pool.Reference())
+	if err != nil || cluster == nil {
+		return err
+	}
+	p.affinityMu.Lock()
+	defer p.affinityMu.Unlock()
+
+	config, err := cluster.Configuration(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster configuration: %w", err)
+	}
+	rule := findAntiAffinityRule(config, name)
+	if rule == nil || !slices.Contains(rule.Vm, vm.Reference()) {
+		return nil
+	}
+	members := lo.Without(rule.Vm, vm.Reference())
+	return reconfigureRule(ctx, cluster, antiAffinityRuleSpec(rule, name, lo.FromPtr(rule.Mandatory), members))
+}
+
+// nodePoolVMs returns the VMs of the cluster launched for the NodePool on the
+// hosts of the DRS cluster, vCenter rejects rules with VMs of other clusters.
+func (p *DefaultProvider) nodePoolVMs(ctx context.Context, cluster *object.ClusterComputeResource, nodePool string) ([]types.ManagedObjectReference, error) {
+	hosts, err := cluster.Hosts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster hosts: %w", err)
+	}
+	clusterHosts := lo.SliceToMap(hosts, func(h *object.HostSystem) (types.ManagedObjectReference, bool) { return h.Reference(), true })
+	vms, err := p.Finder.ListVMProperties(ctx, []string{"runtime.host"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	vms = lo.Filter(vms, func(vm mo.VirtualMachine, _ int) bool {
+		return vm.Runtime.Host != nil && clusterHosts[*vm.Runtime.Host]
+	})
+	vmTags, err := p.Finder.TagsFromVMs(ctx, lo.Map(vms, func(vm mo.VirtualMachine, _ int) types.ManagedObjectReference { return vm.Self }))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags of VMs: %w", err)
+	}
+	var refs []types.ManagedObjectReference
+	for _, vm := range vms {
+		tags := vmTags[vm.Self]
+		if tags[v1alpha1.ClusterNameTagKey] == p.ClusterName && tags[karpv1.NodePoolLabelKey] == nodePool {
+			refs = append(refs, vm.Self)
+		}
+	}
+	return refs, nil
+}
+
+func findAntiAffinityRule(config *types.ClusterConfigInfoEx, name string) *types.ClusterAntiAffinityRuleSpec {
+	for _, r := range config.Rule {
+		if rule, ok := r.(*types.ClusterAntiAffinityRuleSpec); ok && rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// antiAffinityRuleSpec returns the change turning the existing rule into a
+// rule holding vms, nil when there is nothing to change.
+func antiAffinityRuleSpec(rule *types.ClusterAntiAffinityRuleSpec, name string, mandatory bool, vms []types.ManagedObjectReference) *types.ClusterRuleSpec {
+	vms = lo.Uniq(vms)
+	if len(vms) < 2 {
+		if rule == nil {
+			return nil
+		}
+		return &types.ClusterRuleSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{
+				Operation: types.ArrayUpdateOperationRemove,
+				RemoveKey: rule.Key,
+			},
+		}
+	}
+	info := &types.ClusterAntiAffinityRuleSpec{
+		ClusterRuleInfo: types.ClusterRuleInfo{
+			Name:      name,
+			Enabled:   lo.ToPtr(true),
+			Mandatory: lo.ToPtr(mandatory),
+		},
+		Vm: vms,
+	}
+	operation := types.ArrayUpdateOperationAdd
+	if rule != nil {
+		operation = types.ArrayUpdateOperationEdit
+		info.Key = rule.Key
+	}
+	return &types.ClusterRuleSpec{
+		ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: operation},
+		Info:            info,
+	}
+}
+
+func reconfigureRule(ctx context.Context, cluster *object.ClusterComputeResource, spec *types.ClusterRuleSpec) error {
+	if spec == nil {
+		return nil
+	}
+	task, err := cluster.Reconfigure(ctx, &types.ClusterConfigSpecEx{
+		RulesSpec: []types.ClusterRuleSpec{*spec},
+	}, true)
+	if err != nil {
+		return fmt.Errorf("failed to update DRS rule: %w", err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to update DRS rule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+func TestAntiAffinityRuleSpec(t *testing.T) {
+	vm1 := types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1"}
+	vm2 := types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-2"}
+	existing := &types.ClusterAntiAffinityRuleSpec{
+		ClusterRuleInfo: types.ClusterRuleInfo{Key: 7, Name: "rule", Mandatory: lo.ToPtr(true)},
+		Vm:              []types.ManagedObjectReference{vm1, vm2},
+	}
+
+	tests := []struct {
+		name      string
+		rule      *types.ClusterAntiAffinityRuleSpec
+		vms       []types.ManagedObjectReference
+		operation types.ArrayUpdateOperation
+		key       int32
+		members   int
+	}{
+		{name: "single VM without rule", vms: []types.ManagedObjectReference{vm1}},
+		{name: "duplicate VM without rule", vms: []types.ManagedObjectReference{vm1, vm1}},
+		{name: "create rule", vms: []types.ManagedObjectReference{vm1, vm2}, operation: types.ArrayUpdateOperationAdd, members: 2},
+		{name: "edit rule", rule: existing, vms: []types.ManagedObjectReference{vm1, vm2, {Type: "VirtualMachine", Value: "vm-3"}}, operation: types.ArrayUpdateOperationEdit, key: 7, members: 3},
+		{name: "remove rule", rule: existing, vms: []types.ManagedObjectReference{vm2}, operation: types.ArrayUpdateOperationRemove, key: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := antiAffinityRuleSpec(tt.rule, "rule", true, tt.vms)
+			if tt.operation == "" {
+				assert.Nil(t, spec)
+				return
+			}
+			assert.Equal(t, tt.operation, spec.Operation)
+			if tt.operation == types.ArrayUpdateOperationRemove {
+				assert.Equal(t, tt.key, spec.RemoveKey)
+				assert.Nil(t, spec.Info)
+				return
+			}
+			info := spec.Info.(*types.ClusterAntiAffinityRuleSpec)
+			assert.Equal(t, tt.key, info.Key)
+			assert.Equal(t, "rule", info.Name)
+			assert.True(t, *info.Mandatory)
+			assert.Len(t, info.Vm, tt.members)
+		})
+	}
+}
+
+func TestNodePoolVMs(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTestProvider(ctx, t, c)
+		findClient := find.NewFinder(c, true)
+		dc, err := findClient.Datacenter(ctx, "DC0")
+		require.NoError(t, err)
+		folders, err := dc.Folders(ctx)
+		require.NoError(t, err)
+		folder, err := folders.VmFolder.CreateFolder(ctx, "karpenter")
+		require.NoError(t, err)
+		// one VM of the NodePool runs on the standalone host
+		vms, err := findClient.VirtualMachineList(ctx, "/DC0/vm/DC0_*_VM0")
+		require.NoError(t, err)
+		require.Len(t, vms, 2)
+		refs := lo.Map(vms, func(vm *object.VirtualMachine, _ int) types.ManagedObjectReference { return vm.Reference() })
+		task, err := folder.MoveInto(ctx, refs)
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		for _, ref := range refs {
+			require.NoError(t, p.Finder.TagInstance(ctx, ref, map[string]string{v1alpha1.ClusterNameTagKey: "DC0", karpv1.NodePoolLabelKey: "default"}))
+		}
+		cluster, err := findClient.ClusterComputeResource(ctx, "/DC0/host/DC0_C0")
+		require.NoError(t, err)
+		clusterVM, err := findClient.VirtualMachine(ctx, "/DC0/vm/karpenter/DC0_C0_RP0_VM0")
+		require.NoError(t, err)
+
+		members, err := p.nodePoolVMs(ctx, cluster, "default")
+		require.NoError(t, err)
+		assert.Equal(t, []types.ManagedObjectReference{clusterVM.Reference()}, members)
+
+		members, err = p.nodePoolVMs(ctx, cluster, "other")
+		require.NoError(t, err)
+		assert.Empty(t, members)
+	})
+}
@@ -0,0 +1,46 @@
+package instance
+
+import (
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/samber/lo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// applyAllocation sets the CPU and memory reservations, limits and shares of
+// the instance type on the clone config. Guaranteed instance types and high
+// latency sensitivity reserve all of the VM memory.
+func applyAllocation(config *types.VirtualMachineConfigSpec, instanceType v1alpha1.InstanceType) {
+	if instanceType.CPUAllocation != nil {
+		config.CpuAllocation = resourceAllocationInfo(instanceType.CPUAllocation)
+	}
+	if instanceType.MemoryAllocation != nil {
+		config.MemoryAllocation = resourceAllocationInfo(instanceType.MemoryAllocation)
+	}
+	if instanceType.LatencySensitivity != "" {
+		config.LatencySensitivity = &types.LatencySensitivity{
+			Level: types.LatencySensitivitySensitivityLevel(instanceType.LatencySensitivity),
+		}
+	}
+	if instanceType.InstanceClass() == v1alpha1.InstanceClassGuaranteed ||
+		instanceType.LatencySensitivity == string(types.LatencySensitivitySensitivityLevelHigh) {
+		if config.MemoryAllocation == nil {
+			config.MemoryAllocation = &types.ResourceAllocationInfo{}
+		}
+		config.MemoryAllocation.Reservation = lo.ToPtr(config.MemoryMB)
+		config.MemoryReservationLockedToMax = lo.ToPtr(true)
+	}
+}
+
+func resourceAllocationInfo(allocation *v1alpha1.ResourceAllocation) *types.ResourceAllocationInfo {
+	info := &types.ResourceAllocationInfo{
+		Reservation: allocation.Reservation,
+		Limit:       allocation.Limit,
+	}
+	if allocation.SharesLevel != "" {
+		info.Shares = &types.SharesInfo{
+			Level:  types.SharesLevel(allocation.SharesLevel),
+			Shares: allocation.Shares,
+		}
+	}
+	return info
+}
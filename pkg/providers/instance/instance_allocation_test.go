@@ -0,0 +1,66 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+func TestApplyAllocation(t *testing.T) {
+	tests := []struct {
+		name                string
+		instanceType        v1alpha1.InstanceType
+		expectedCPU         *types.ResourceAllocationInfo
+		expectedMemory      *types.ResourceAllocationInfo
+		expectedLockedToMax bool
+	}{
+		{
+			name:         "burstable without allocation leaves the config untouched",
+			instanceType: v1alpha1.InstanceType{},
+		},
+		{
+			name: "explicit allocation is passed through",
+			instanceType: v1alpha1.InstanceType{
+				CPUAllocation: &v1alpha1.ResourceAllocation{
+					Reservation: lo.ToPtr(int64(2000)),
+					SharesLevel: "custom",
+					Shares:      4000,
+				},
+				MemoryAllocation: &v1alpha1.ResourceAllocation{
+					Limit: lo.ToPtr(int64(-1)),
+				},
+			},
+			expectedCPU: &types.ResourceAllocationInfo{
+				Reservation: lo.ToPtr(int64(2000)),
+				Shares:      &types.SharesInfo{Level: types.SharesLevelCustom, Shares: 4000},
+			},
+			expectedMemory: &types.ResourceAllocationInfo{
+				Limit: lo.ToPtr(int64(-1)),
+			},
+		},
+		{
+			name: "guaranteed reserves all memory",
+			instanceType: v1alpha1.InstanceType{
+				Class: v1alpha1.InstanceClassGuaranteed,
+			},
+			expectedMemory: &types.ResourceAllocationInfo{
+				Reservation: lo.ToPtr(int64(4096)),
+			},
+			expectedLockedToMax: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &types.VirtualMachineConfigSpec{MemoryMB: 4096}
+			applyAllocation(config, test.instanceType)
+			assert.Equal(t, test.expectedCPU, config.CpuAllocation)
+			assert.Equal(t, test.expectedMemory, config.MemoryAllocation)
+			assert.Equal(t, test.expectedLockedToMax, lo.FromPtr(config.MemoryReservationLockedToMax))
+		})
+	}
+}
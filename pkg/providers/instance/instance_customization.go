@@ -0,0 +1,129 @@
+package instance
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/operator/options"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// windowsComputerNameMaxLength is the NetBIOS limit Sysprep enforces on the computer name
+	windowsComputerNameMaxLength = 15
+	// windowsComputerNameHashLength is the hash suffix kept when truncating names
+	windowsComputerNameHashLength = 5
+	windowsDefaultTimeZone        = 85
+	windowsWorkgroup              = "WORKGROUP"
+	windowsAdminPasswordKey       = "password"
+	// windowsJoinCommand reads the join script from guestinfo through VMware Tools
+	// and runs it once Sysprep has finished.
+	windowsJoinCommand = `powershell.exe -NoProfile -ExecutionPolicy Bypass -Command "$d = & 'C:\Program Files\VMware\VMware Tools\rpctool.exe' 'info-get ` + guestInfoUserData + `'; ` +
+		`[IO.File]::WriteAllText('C:\karpenter-join.ps1', [Text.Encoding]::UTF8.GetString([Convert]::FromBase64String($d))); & 'C:\karpenter-join.ps1'"`
+)
+
+// GetWindowsCustomization returns the Sysprep customization applied when cloning
+// a Windows node, either generated from the NodeClass or loaded from vCenter.
+func (p *DefaultProvider) GetWindowsCustomization(ctx context.Context, class *v1alpha1.VsphereNodeClass, claimName string) (*types.CustomizationSpec, error) {
+	windows := class.Spec.Windows
+	if windows == nil {
+		return nil, fmt.Errorf("windows instance types require the windows customization of the nodeclass")
+	}
+	if windows.CustomizationSpec != "" {
+		item, err := object.NewCustomizationSpecManager(p.Finder.Client).GetCustomizationSpec(ctx, windows.CustomizationSpec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get customization spec %s: %w", windows.CustomizationSpec, err)
+		}
+		sysprep, ok := item.Spec.Identity.(*types.CustomizationSysprep)
+		if !ok {
+			return nil, fmt.Errorf("customization spec %s is not a Sysprep spec", windows.CustomizationSpec)
+		}
+		if sysprep.GuiRunOnce == nil {
+			sysprep.GuiRunOnce = &types.CustomizationGuiRunOnce{}
+		}
+		sysprep.GuiRunOnce.CommandList = append(sysprep.GuiRunOnce.CommandList, windowsJoinCommand)
+		// the run once commands only run at the first logon
+		sysprep.GuiUnattended.AutoLogon = true
+		sysprep.GuiUnattended.AutoLogonCount = max(sysprep.GuiUnattended.AutoLogonCount, 1)
+		return &item.Spec, nil
+	}
+
+	password, err := p.getWindowsAdminPassword(ctx, windows.AdminPasswordSecretName)
+	if err != nil {
+		return nil, err
+	}
+	timeZone := windows.TimeZone
+	if timeZone == 0 {
+		timeZone = windowsDefaultTimeZone
+	}
+	return &types.CustomizationSpec{
+		Identity: &types.CustomizationSysprep{
+			// the run once commands only run at the first logon
+			GuiUnattended: types.CustomizationGuiUnattended{
+				Password:       password,
+				TimeZone:       timeZone,
+				AutoLogon:      true,
+				AutoLogonCount: 1,
+			},
+			UserData: types.CustomizationUserData{
+				FullName: "karpenter",
+				OrgName:  windows.Organization,
+				ComputerName: &types.CustomizationFixedName{
+					Name: windowsComputerName(claimName),
+				},
+				ProductId: windows.ProductKey,
+			},
+			GuiRunOnce: &types.CustomizationGuiRunOnce{
+				CommandList: []string{windowsJoinCommand},
+			},
+			Identification: types.CustomizationIdentification{
+				JoinWorkgroup: windowsWorkgroup,
+			},
+		},
+		GlobalIPSettings: types.CustomizationGlobalIPSettings{},
+		NicSettingMap: []types.CustomizationAdapterMapping{
+			{
+				Adapter: types.CustomizationIPSettings{
+					Ip: &types.CustomizationDhcpIpGenerator{},
+				},
+			},
+		},
+	}, nil
+}
+
+func (p *DefaultProvider) getWindowsAdminPassword(ctx context.Context, secretName string) (*types.CustomizationPassword, error) {
+	// Sysprep would otherwise leave the Administrator password blank
+	if secretName == "" {
+		return nil, fmt.Errorf("windows adminPasswordSecretName is required without a customizationSpec")
+	}
+	namespace := options.FromContext(ctx).SystemNamespace
+	secret, err := p.kubeClient.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get windows admin password secret %s/%s: %w", namespace, secretName, err)
+	}
+	password := secret.Data[windowsAdminPasswordKey]
+	if len(password) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no %s key", namespace, secretName, windowsAdminPasswordKey)
+	}
+	return &types.CustomizationPassword{
+		Value:     string(password),
+		PlainText: true,
+	}, nil
+}
+
+// windowsComputerName derives a Sysprep compatible computer name from the
+// NodeClaim name, which is unique within the cluster. Longer names keep their
+// end and a short hash of the full name, so truncated names don't collide.
+func windowsComputerName(claimName string) string {
+	if len(claimName) <= windowsComputerNameMaxLength {
+		return strings.Trim(claimName, "-")
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(claimName)))[:windowsComputerNameHashLength]
+	name := claimName[len(claimName)-(windowsComputerNameMaxLength-windowsComputerNameHashLength-1):]
+	return strings.Trim(name, "-") + "-" + hash
+}
@@ -1,10 +1,20 @@
 package instance
 
 import (
+   "context"
    "testing"
 
    "github.com/stretchr/testify/assert"
+   "github.com/stretchr/testify/require"
+   "github.com/vmware/govmomi/find"
+   "github.com/vmware/govmomi/object"
+   "github.com/vmware/govmomi/simulator"
+   "github.com/vmware/govmomi/vim25"
    "github.com/vmware/govmomi/vim25/types"
+
+   "github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+
+   _ "github.com/vmware/govmomi/pbm/simulator"
 )
 
 func TestGetDiskConfigSpecResize(t *testing.T) {
@@ -75,4 +85,93 @@ func TestGetDiskConfigSpecResize(t *testing.T) {
          assert.Equal(t, test.expectedDiskSize, resultDisk.CapacityInKB)
       })
    }
-}
\ No newline at end of file
+}
+
+// setDatastoreCapabilities changes how the simulated datastore backs disks
+func setDatastoreCapabilities(ctx context.Context, ds *object.Datastore, dsType string, thin bool) {
+	obj := simulator.Map(ctx).Get(ds.Reference()).(*simulator.Datastore)
+	obj.Summary.Type = dsType
+	obj.Capability.PerFileThinProvisioningSupported = thin
+}
+
+func TestCheckDiskProvisioning(t *testing.T) {
+	tests := []struct {
+		name          string
+		dsType        string
+		thin          bool
+		provisioning  v1alpha1.DiskProvisioning
+		errorContains string
+	}{
+		{name: "thin", dsType: "VMFS", thin: true, provisioning: v1alpha1.DiskProvisioningThin},
+		{name: "thick", dsType: "VMFS", thin: true, provisioning: v1alpha1.DiskProvisioningThick},
+		{name: "thin unsupported", dsType: "NFS", thin: false, provisioning: v1alpha1.DiskProvisioningThin, errorContains: "does not support thin"},
+		{name: "thin on vSAN", dsType: vsanDatastoreType, thin: true, provisioning: v1alpha1.DiskProvisioningThin},
+		{name: "thick on vSAN", dsType: vsanDatastoreType, thin: true, provisioning: v1alpha1.DiskProvisioningEagerZeroedThick, errorContains: "storage policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, c *vim25.Client) {
+				ds, err := find.NewFinder(c, true).Datastore(ctx, "/DC0/datastore/LocalDS_0")
+				require.NoError(t, err)
+				setDatastoreCapabilities(ctx, ds, tt.dsType, tt.thin)
+				err = checkDiskProvisioning(ctx, ds, tt.provisioning)
+				if tt.errorContains != "" {
+					assert.ErrorContains(t, err, tt.errorContains)
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestGetRootDiskLocator(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTestProvider(ctx, t, c)
+		findClient := find.NewFinder(c, true)
+		template, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		require.NoError(t, err)
+		ds, err := findClient.Datastore(ctx, "/DC0/datastore/LocalDS_0")
+		require.NoError(t, err)
+		setDatastoreCapabilities(ctx, ds, vsanDatastoreType, true)
+		disk, err := template.Device(ctx)
+		require.NoError(t, err)
+		diskKey := disk.SelectByType((*types.VirtualDisk)(nil))[0].GetVirtualDevice().Key
+
+		class := &v1alpha1.VsphereNodeClass{}
+		locator, err := p.GetRootDiskLocator(ctx, class, template, ds.Reference())
+		require.NoError(t, err)
+		assert.Nil(t, locator, "the template disk is kept")
+
+		class.Spec.RootDisk = v1alpha1.RootDisk{Provisioning: v1alpha1.DiskProvisioningThick}
+		_, err = p.GetRootDiskLocator(ctx, class, template, ds.Reference())
+		assert.ErrorContains(t, err, "storage policy", "thick on vSAN needs a storage policy")
+
+		class.Spec.RootDisk.StoragePolicy = "vSAN Default Storage Policy"
+		locator, err = p.GetRootDiskLocator(ctx, class, template, ds.Reference())
+		require.NoError(t, err)
+		assert.Equal(t, diskKey, locator.DiskId)
+		assert.Equal(t, ds.Reference(), locator.Datastore)
+		backing := locator.DiskBackingInfo.(*types.VirtualDiskFlatVer2BackingInfo)
+		assert.False(t, *backing.ThinProvisioned)
+		assert.False(t, *backing.EagerlyScrub)
+		require.Len(t, locator.Profile, 1)
+		assert.NotEmpty(t, locator.Profile[0].(*types.VirtualMachineDefinedProfileSpec).ProfileId)
+
+		// the client is reused for the next lookups
+		pbmClient := p.storagePolicies.client
+		_, err = p.storagePolicyID(ctx, "vSAN Default Storage Policy")
+		require.NoError(t, err)
+		assert.Same(t, pbmClient, p.storagePolicies.client)
+		_, err = p.storagePolicyID(ctx, "missing")
+		assert.ErrorContains(t, err, "missing")
+
+		class.Spec.RootDisk = v1alpha1.RootDisk{DatastoreSelector: &v1alpha1.DatastoreSelectorTerm{Name: "LocalDS_0"}}
+		class.Spec.Encryption = &v1alpha1.Encryption{KeyProvider: "kms"}
+		locator, err = p.GetRootDiskLocator(ctx, class, template, types.ManagedObjectReference{Type: "Datastore", Value: "other"})
+		require.NoError(t, err)
+		assert.Equal(t, ds.Reference(), locator.Datastore, "the root disk datastore is selected by name")
+		assert.Nil(t, locator.DiskBackingInfo)
+		assert.Empty(t, locator.Profile)
+	})
+}
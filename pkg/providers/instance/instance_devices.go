@@ -3,13 +3,20 @@ package instance
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	v1alpha1 "github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/utils"
+	"github.com/samber/lo"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/pbm"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
+const vsanDatastoreType = "vsan"
+
 const ethCardType = "vmxnet3"
 
 func (p *DefaultProvider) getNetworkSpecs(ctx context.Context, networkName object.NetworkReference, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
@@ -51,12 +58,8 @@ func (p *DefaultProvider) getNetworkSpecs(ctx context.Context, networkName objec
 	}), err
 }
 
-func (p *DefaultProvider) GetDeviceSpec(ctx context.Context, class *v1alpha1.VsphereNodeClass, diskSize int64) ([]types.BaseVirtualDeviceConfigSpec, error) {
+func (p *DefaultProvider) GetDeviceSpec(ctx context.Context, class *v1alpha1.VsphereNodeClass, vmTemplate *object.VirtualMachine, diskSize int64) ([]types.BaseVirtualDeviceConfigSpec, error) {
 	var deviceChange []types.BaseVirtualDeviceConfigSpec
-	vmTemplate, err := p.Finder.ResolveImage(ctx, class.Spec.ImageSelector)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find VM template: %w", err)
-	}
 	network, err := p.Finder.ResolveNetwork(ctx, class.Spec.NetworkSelector)
 	if err != nil {
 		return nil, err
@@ -104,3 +107,116 @@ func getDiskConfigSpec(disk *types.VirtualDisk, diskCloneCapacityKB int64) (type
 		Device:    disk,
 	}, nil
 }
+
+// GetRootDiskLocator returns the relocation of the template root disk when the
+// NodeClass overrides its provisioning, datastore or storage policy, or when
+// the disk has to be encrypted.
+func (p *DefaultProvider) GetRootDiskLocator(ctx context.Context, class *v1alpha1.VsphereNodeClass, vmTemplate *object.VirtualMachine, vmDatastore types.ManagedObjectReference) (*types.VirtualMachineRelocateSpecDiskLocator, error) {
+	rootDisk := class.Spec.RootDisk
+	if rootDisk.IsZero() && class.Spec.Encryption == nil {
+		return nil, nil
+	}
+	devList, err := vmTemplate.Device(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device list from VM template: %w", err)
+	}
+	disks := devList.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("invalid disk count: %d", len(disks))
+	}
+	primaryDisk := disks[0].(*types.VirtualDisk)
+
+	datastore := object.NewDatastore(p.Finder.Client, vmDatastore)
+	if rootDisk.DatastoreSelector != nil {
+		datastore, err = p.Finder.ResolveDatastore(ctx, *rootDisk.DatastoreSelector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve root disk datastore: %w", err)
+		}
+	}
+	locator := &types.VirtualMachineRelocateSpecDiskLocator{
+		DiskId:    primaryDisk.Key,
+		Datastore: datastore.Reference(),
+	}
+	if rootDisk.Provisioning != "" {
+		// a storage policy decides how the datastore backs the disk, e.g. on vSAN
+		if rootDisk.StoragePolicy == "" {
+			if err := checkDiskProvisioning(ctx, datastore, rootDisk.Provisioning); err != nil {
+				return nil, err
+			}
+		}
+		locator.DiskBackingInfo = &types.VirtualDiskFlatVer2BackingInfo{
+			DiskMode:        string(types.VirtualDiskModePersistent),
+			ThinProvisioned: lo.ToPtr(rootDisk.Provisioning == v1alpha1.DiskProvisioningThin),
+			EagerlyScrub:    lo.ToPtr(rootDisk.Provisioning == v1alpha1.DiskProvisioningEagerZeroedThick),
+		}
+	}
+	if rootDisk.StoragePolicy != "" {
+		profileID, err := p.storagePolicyID(ctx, rootDisk.StoragePolicy)
+		if err != nil {
+			return nil, err
+		}
+		locator.Profile = []types.BaseVirtualMachineProfileSpec{
+			&types.VirtualMachineDefinedProfileSpec{ProfileId: profileID},
+		}
+	}
+	return locator, nil
+}
+
+// checkDiskProvisioning returns an error when the datastore can't back a disk
+// in the requested format, instead of failing the clone task half way.
+func checkDiskProvisioning(ctx context.Context, datastore *object.Datastore, provisioning v1alpha1.DiskProvisioning) error {
+	var dsMo mo.Datastore
+	err := datastore.Properties(ctx, datastore.Reference(), []string{"name", "summary.type", "capability.perFileThinProvisioningSupported"}, &dsMo)
+	if err != nil {
+		return fmt.Errorf("failed to get datastore capabilities: %w", err)
+	}
+	switch {
+	case provisioning == v1alpha1.DiskProvisioningThin && !dsMo.Capability.PerFileThinProvisioningSupported:
+		return fmt.Errorf("datastore %s does not support thin provisioned disks", dsMo.Name)
+	case provisioning != v1alpha1.DiskProvisioningThin && dsMo.Summary.Type == vsanDatastoreType:
+		return fmt.Errorf("datastore %s is a vSAN datastore, %s provisioning must be requested through a storage policy", dsMo.Name, provisioning)
+	}
+	return nil
+}
+
+// storagePolicies holds the storage policy client of the vCenter, created on
+// first use
+type storagePolicies struct {
+	mu     sync.Mutex
+	client *pbm.Client
+}
+
+// get returns the client, a new one when renew is set
+func (s *storagePolicies) get(ctx context.Context, c *vim25.Client, renew bool) (*pbm.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil && !renew {
+		return s.client, nil
+	}
+	client, err := pbm.NewClient(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage policy client: %w", err)
+	}
+	s.client = client
+	return client, nil
+}
+
+func (p *DefaultProvider) storagePolicyID(ctx context.Context, name string) (string, error) {
+	pbmClient, err := p.storagePolicies.get(ctx, p.Finder.Client, false)
+	if err != nil {
+		return "", err
+	}
+	id, err := pbmClient.ProfileIDByName(ctx, name)
+	if err != nil {
+		// the client is bound to the session it was created in, try once more
+		// with a new client in case the session was renewed
+		if pbmClient, err = p.storagePolicies.get(ctx, p.Finder.Client, true); err != nil {
+			return "", err
+		}
+		id, err = pbmClient.ProfileIDByName(ctx, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find storage policy %s: %w", name, err)
+	}
+	return id, nil
+}
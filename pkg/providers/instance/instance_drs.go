@@ -0,0 +1,77 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	corecloudprovider "sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+// placeVM asks DRS where to clone the VM and updates the clone spec with the
+// recommended host and datastore, so admission control and datastore balance
+// are respected before the clone starts.
+func (p *DefaultProvider) placeVM(ctx context.Context, vmTemplate *object.VirtualMachine, name string, cloneSpec *types.VirtualMachineCloneSpec) error {
+	cluster, err := p.poolCluster(ctx, *cloneSpec.Location.Pool)
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		return fmt.Errorf("DRS placement requires a DRS cluster, resource pool %s belongs to a standalone host", cloneSpec.Location.Pool.Value)
+	}
+	templateRef := vmTemplate.Reference()
+	result, err := cluster.PlaceVm(ctx, types.PlacementSpec{
+		PlacementType: string(types.PlacementSpecPlacementTypeClone),
+		Vm:            &templateRef,
+		CloneSpec:     cloneSpec,
+		CloneName:     name,
+		RelocateSpec:  &cloneSpec.Location,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get DRS placement: %w", err)
+	}
+	return applyPlacement(result, cloneSpec)
+}
+
+// applyPlacement applies the first DRS recommendation to the clone spec, disks
+// placed with the VM follow it to the recommended datastore.
+func applyPlacement(result *types.PlacementResult, cloneSpec *types.VirtualMachineCloneSpec) error {
+	for _, recommendation := range result.Recommendations {
+		for _, a := range recommendation.Action {
+			action, ok := a.(*types.PlacementAction)
+			if !ok || action.TargetHost == nil {
+				continue
+			}
+			cloneSpec.Location.Host = action.TargetHost
+			if action.RelocateSpec != nil && action.RelocateSpec.Datastore != nil {
+				current := cloneSpec.Location.Datastore
+				for i := range cloneSpec.Location.Disk {
+					if current != nil && cloneSpec.Location.Disk[i].Datastore == *current {
+						cloneSpec.Location.Disk[i].Datastore = *action.RelocateSpec.Datastore
+					}
+				}
+				cloneSpec.Location.Datastore = action.RelocateSpec.Datastore
+			}
+			return nil
+		}
+	}
+	return corecloudprovider.NewInsufficientCapacityError(fmt.Errorf("DRS rejected the placement: %s", drsFaultReason(result.DrsFault)))
+}
+
+func drsFaultReason(fault *types.ClusterDrsFaults) string {
+	if fault == nil {
+		return "no recommendation"
+	}
+	var messages []string
+	for _, byVM := range fault.FaultsByVm {
+		for _, f := range byVM.GetClusterDrsFaultsFaultsByVm().Fault {
+			messages = append(messages, f.LocalizedMessage)
+		}
+	}
+	if len(messages) == 0 {
+		return fault.Reason
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,48 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi/vim25/types"
+	corecloudprovider "sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+func TestApplyPlacement(t *testing.T) {
+	oldDS := types.ManagedObjectReference{Type: "Datastore", Value: "ds-old"}
+	newDS := types.ManagedObjectReference{Type: "Datastore", Value: "ds-new"}
+	otherDS := types.ManagedObjectReference{Type: "Datastore", Value: "ds-other"}
+	host := types.ManagedObjectReference{Type: "HostSystem", Value: "host-1"}
+	cloneSpec := &types.VirtualMachineCloneSpec{
+		Location: types.VirtualMachineRelocateSpec{
+			Datastore: &oldDS,
+			Disk: []types.VirtualMachineRelocateSpecDiskLocator{
+				{DiskId: 1, Datastore: oldDS},
+				{DiskId: 2, Datastore: otherDS},
+			},
+		},
+	}
+	err := applyPlacement(&types.PlacementResult{
+		Recommendations: []types.ClusterRecommendation{{
+			Action: []types.BaseClusterAction{&types.PlacementAction{
+				TargetHost:   &host,
+				RelocateSpec: &types.VirtualMachineRelocateSpec{Datastore: &newDS},
+			}},
+		}},
+	}, cloneSpec)
+	assert.NoError(t, err)
+	assert.Equal(t, host, *cloneSpec.Location.Host)
+	assert.Equal(t, newDS, *cloneSpec.Location.Datastore)
+	assert.Equal(t, newDS, cloneSpec.Location.Disk[0].Datastore)
+	assert.Equal(t, otherDS, cloneSpec.Location.Disk[1].Datastore)
+
+	err = applyPlacement(&types.PlacementResult{
+		DrsFault: &types.ClusterDrsFaults{
+			FaultsByVm: []types.BaseClusterDrsFaultsFaultsByVm{&types.ClusterDrsFaultsFaultsByVm{
+				Fault: []types.LocalizedMethodFault{{LocalizedMessage: "insufficient resources"}},
+			}},
+		},
+	}, &types.VirtualMachineCloneSpec{})
+	assert.True(t, corecloudprovider.IsInsufficientCapacityError(err))
+	assert.ErrorContains(t, err, "insufficient resources")
+}
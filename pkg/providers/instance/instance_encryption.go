@@ -0,0 +1,53 @@
+package instance
+
+import (
+	"context"
+
+	v1alpha1 "github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// applyEncryption makes the clone encrypt the VM home and the relocated disks,
+// either with a new key from the key provider or through an encryption
+// storage policy.
+func (p *DefaultProvider) applyEncryption(ctx context.Context, encryption *v1alpha1.Encryption, cloneSpec *types.VirtualMachineCloneSpec) error {
+	if encryption == nil {
+		return nil
+	}
+	if encryption.KeyProvider != "" {
+		encryptWithKeyProvider(cloneSpec, encryption.KeyProvider)
+		return nil
+	}
+	profileID, err := p.storagePolicyID(ctx, encryption.StoragePolicy)
+	if err != nil {
+		return err
+	}
+	encryptWithStoragePolicy(cloneSpec, profileID)
+	return nil
+}
+
+func encryptWithKeyProvider(cloneSpec *types.VirtualMachineCloneSpec, keyProvider string) {
+	// An empty key ID lets the key provider generate a new key
+	crypto := &types.CryptoSpecEncrypt{
+		CryptoKeyId: types.CryptoKeyId{
+			ProviderId: &types.KeyProviderId{Id: keyProvider},
+		},
+	}
+	cloneSpec.Config.Crypto = crypto
+	for i := range cloneSpec.Location.Disk {
+		cloneSpec.Location.Disk[i].Backing = &types.VirtualMachineRelocateSpecDiskLocatorBackingSpec{
+			Crypto: crypto,
+		}
+	}
+}
+
+func encryptWithStoragePolicy(cloneSpec *types.VirtualMachineCloneSpec, profileID string) {
+	cloneSpec.Location.Profile = []types.BaseVirtualMachineProfileSpec{
+		&types.VirtualMachineDefinedProfileSpec{ProfileId: profileID},
+	}
+	for i := range cloneSpec.Location.Disk {
+		cloneSpec.Location.Disk[i].Profile = []types.BaseVirtualMachineProfileSpec{
+			&types.VirtualMachineDefinedProfileSpec{ProfileId: profileID},
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestEncryptWithKeyProvider(t *testing.T) {
+	cloneSpec := &types.VirtualMachineCloneSpec{
+		Config: &types.VirtualMachineConfigSpec{},
+		Location: types.VirtualMachineRelocateSpec{
+			Disk: []types.VirtualMachineRelocateSpecDiskLocator{{DiskId: 2000}},
+		},
+	}
+	encryptWithKeyProvider(cloneSpec, "kms-cluster")
+
+	crypto, ok := cloneSpec.Config.Crypto.(*types.CryptoSpecEncrypt)
+	assert.True(t, ok)
+	assert.Equal(t, "kms-cluster", crypto.CryptoKeyId.ProviderId.Id)
+	assert.Empty(t, crypto.CryptoKeyId.KeyId)
+	assert.Equal(t, crypto, cloneSpec.Location.Disk[0].Backing.Crypto)
+}
+
+func TestEncryptWithStoragePolicy(t *testing.T) {
+	cloneSpec := &types.VirtualMachineCloneSpec{
+		Config: &types.VirtualMachineConfigSpec{},
+		Location: types.VirtualMachineRelocateSpec{
+			Disk: []types.VirtualMachineRelocateSpecDiskLocator{{DiskId: 2000}},
+		},
+	}
+	encryptWithStoragePolicy(cloneSpec, "policy-id")
+
+	expected := []types.BaseVirtualMachineProfileSpec{
+		&types.VirtualMachineDefinedProfileSpec{ProfileId: "policy-id"},
+	}
+	assert.Nil(t, cloneSpec.Config.Crypto)
+	assert.Equal(t, expected, cloneSpec.Location.Profile)
+	assert.Equal(t, expected, cloneSpec.Location.Disk[0].Profile)
+}
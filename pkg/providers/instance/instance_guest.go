@@ -0,0 +1,131 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	corecloudprovider "sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/vmware/govmomi/object"
+	models "github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// arm64GuestID is the generic 64-bit guest identifier on ESXi-Arm hosts,
+// govmomi has no constants for the Arm guest identifiers.
+const arm64GuestID = "arm-other-64"
+
+// instanceTypeArch returns the architecture an instance type is constrained to,
+// instance types without an architecture requirement are treated as amd64.
+func instanceTypeArch(instanceType *corecloudprovider.InstanceType) string {
+	if instanceType == nil || !instanceType.Requirements.Has(corev1.LabelArchStable) {
+		return karpv1.ArchitectureAmd64
+	}
+	// Only In requirements name a value, Any picks a random one otherwise
+	requirement := instanceType.Requirements.Get(corev1.LabelArchStable)
+	if requirement.Operator() != corev1.NodeSelectorOpIn {
+		return karpv1.ArchitectureAmd64
+	}
+	return requirement.Any()
+}
+
+// instanceTypeOS returns the operating system an instance type is constrained to,
+// instance types without an OS requirement are treated as linux.
+func instanceTypeOS(instanceType *corecloudprovider.InstanceType) string {
+	if instanceType == nil || !instanceType.Requirements.Has(corev1.LabelOSStable) {
+		return string(corev1.Linux)
+	}
+	// Only In requirements name a value, Any picks a random one otherwise
+	requirement := instanceType.Requirements.Get(corev1.LabelOSStable)
+	if requirement.Operator() != corev1.NodeSelectorOpIn {
+		return string(corev1.Linux)
+	}
+	return requirement.Any()
+}
+
+func guestIDForArch(arch string) string {
+	switch arch {
+	case karpv1.ArchitectureArm64:
+		return arm64GuestID
+	default:
+		return string(types.VirtualMachineGuestOsIdentifierOtherLinux64Guest)
+	}
+}
+
+// guestID returns the guest identifier configured on the NodeClass, falling
+// back to the default identifier for the instance type OS and architecture.
+func guestID(class *v1alpha1.VsphereNodeClass, instanceType *corecloudprovider.InstanceType) string {
+	if class.Spec.GuestOS.GuestID != "" {
+		return class.Spec.GuestOS.GuestID
+	}
+	if instanceTypeOS(instanceType) == string(corev1.Windows) {
+		return string(types.VirtualMachineGuestOsIdentifierWindows2019srv_64Guest)
+	}
+	return guestIDForArch(instanceTypeArch(instanceType))
+}
+
+// applyGuestOS sets firmware, Secure Boot and the vTPM device on the clone
+// config. Anything left unset is inherited from the template. The vTPM needs
+// the upgraded hardware, so with a hardware version it's added by addVTPM.
+func applyGuestOS(config *types.VirtualMachineConfigSpec, guest v1alpha1.GuestOS) {
+	if guest.Firmware != "" {
+		config.Firmware = string(guest.Firmware)
+	}
+	if guest.SecureBoot != nil {
+		config.BootOptions = &types.VirtualMachineBootOptions{
+			EfiSecureBootEnabled: lo.ToPtr(*guest.SecureBoot),
+		}
+	}
+	if guest.VTPM && guest.HardwareVersion == "" {
+		config.DeviceChange = append(config.DeviceChange, vTPMChange())
+	}
+}
+
+func vTPMChange() *types.VirtualDeviceConfigSpec {
+	return &types.VirtualDeviceConfigSpec{
+		Operation: types.VirtualDeviceConfigSpecOperationAdd,
+		Device: &types.VirtualTPM{
+			VirtualDevice: types.VirtualDevice{
+				// Temporary key, vCenter assigns the real one on creation
+				Key: -200,
+			},
+		},
+	}
+}
+
+// addVTPM adds the vTPM device to a cloned VM
+func addVTPM(ctx context.Context, vm *object.VirtualMachine) error {
+	task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		DeviceChange: []types.BaseVirtualDeviceConfigSpec{vTPMChange()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add vTPM: %w", err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to add vTPM: %w", err)
+	}
+	return nil
+}
+
+// upgradeHardwareVersion upgrades the virtual hardware of a powered off VM,
+// VMs already at the requested version are left untouched.
+func upgradeHardwareVersion(ctx context.Context, vm *object.VirtualMachine, version string) error {
+	vmMo := models.VirtualMachine{
+		Config: &types.VirtualMachineConfigInfo{},
+	}
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.version"}, &vmMo); err != nil {
+		return fmt.Errorf("failed to get hardware version: %w", err)
+	}
+	if vmMo.Config.Version == version {
+		return nil
+	}
+	task, err := vm.UpgradeVM(ctx, version)
+	if err != nil {
+		return fmt.Errorf("failed to upgrade hardware version to %s: %w", version, err)
+	}
+	return task.Wait(ctx)
+}
@@ -0,0 +1,198 @@
+package instance
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	corecloudprovider "sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/operator/options"
+)
+
+func TestGuestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		guestID  string
+		arch     string
+		os       string
+		expected string
+	}{
+		{
+			name:     "amd64 defaults to otherLinux64Guest",
+			arch:     karpv1.ArchitectureAmd64,
+			expected: string(types.VirtualMachineGuestOsIdentifierOtherLinux64Guest),
+		},
+		{
+			name:     "arm64 defaults to the arm guest id",
+			arch:     karpv1.ArchitectureArm64,
+			expected: arm64GuestID,
+		},
+		{
+			name:     "windows defaults to the windows server guest id",
+			arch:     karpv1.ArchitectureAmd64,
+			os:       string(corev1.Windows),
+			expected: string(types.VirtualMachineGuestOsIdentifierWindows2019srv_64Guest),
+		},
+		{
+			name:     "nodeclass guest id wins over the architecture default",
+			guestID:  "ubuntu64Guest",
+			arch:     karpv1.ArchitectureArm64,
+			expected: "ubuntu64Guest",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			class := &v1alpha1.VsphereNodeClass{}
+			class.Spec.GuestOS.GuestID = test.guestID
+			instanceType := &corecloudprovider.InstanceType{
+				Requirements: scheduling.NewRequirements(
+					scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, test.arch),
+				),
+			}
+			if test.os != "" {
+				instanceType.Requirements.Add(scheduling.NewRequirement(corev1.LabelOSStable, corev1.NodeSelectorOpIn, test.os))
+			}
+			assert.Equal(t, test.expected, guestID(class, instanceType))
+		})
+	}
+}
+
+func TestInstanceTypeArch(t *testing.T) {
+	tests := []struct {
+		name         string
+		instanceType *corecloudprovider.InstanceType
+		expected     string
+	}{
+		{
+			name:     "nil instance type defaults to amd64",
+			expected: karpv1.ArchitectureAmd64,
+		},
+		{
+			name:         "missing requirement defaults to amd64",
+			instanceType: &corecloudprovider.InstanceType{Requirements: scheduling.NewRequirements()},
+			expected:     karpv1.ArchitectureAmd64,
+		},
+		{
+			name: "arm64 requirement",
+			instanceType: &corecloudprovider.InstanceType{Requirements: scheduling.NewRequirements(
+				scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, karpv1.ArchitectureArm64),
+			)},
+			expected: karpv1.ArchitectureArm64,
+		},
+		{
+			name: "requirement without values defaults to amd64",
+			instanceType: &corecloudprovider.InstanceType{Requirements: scheduling.NewRequirements(
+				scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpExists),
+			)},
+			expected: karpv1.ArchitectureAmd64,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, instanceTypeArch(test.instanceType))
+		})
+	}
+}
+
+func TestApplyGuestOS(t *testing.T) {
+	config := &types.VirtualMachineConfigSpec{}
+	applyGuestOS(config, v1alpha1.GuestOS{})
+	assert.Empty(t, config.Firmware)
+	assert.Nil(t, config.BootOptions)
+	assert.Empty(t, config.DeviceChange)
+
+	applyGuestOS(config, v1alpha1.GuestOS{
+		Firmware:   v1alpha1.FirmwareEFI,
+		SecureBoot: lo.ToPtr(true),
+		VTPM:       true,
+	})
+	assert.Equal(t, string(types.GuestOsDescriptorFirmwareTypeEfi), config.Firmware)
+	assert.True(t, *config.BootOptions.EfiSecureBootEnabled)
+	assert.Len(t, config.DeviceChange, 1)
+	spec := config.DeviceChange[0].(*types.VirtualDeviceConfigSpec)
+	assert.Equal(t, types.VirtualDeviceConfigSpecOperationAdd, spec.Operation)
+	assert.IsType(t, &types.VirtualTPM{}, spec.Device)
+
+	// secure boot enabled on the template is turned off
+	config = &types.VirtualMachineConfigSpec{}
+	applyGuestOS(config, v1alpha1.GuestOS{Firmware: v1alpha1.FirmwareEFI, SecureBoot: lo.ToPtr(false)})
+	assert.False(t, *config.BootOptions.EfiSecureBootEnabled)
+
+	// the vTPM waits for the hardware upgrade
+	config = &types.VirtualMachineConfigSpec{}
+	applyGuestOS(config, v1alpha1.GuestOS{Firmware: v1alpha1.FirmwareEFI, VTPM: true, HardwareVersion: "vmx-19"})
+	assert.Empty(t, config.DeviceChange)
+}
+
+func TestWindowsComputerName(t *testing.T) {
+	assert.Equal(t, "default-abcde", windowsComputerName("default-abcde"))
+	for name, prefix := range map[string]string{"long-nodepool-abcde": "ool-abcde-", "pool----------abcde": "abcde-"} {
+		computerName := windowsComputerName(name)
+		assert.LessOrEqual(t, len(computerName), windowsComputerNameMaxLength)
+		assert.True(t, strings.HasPrefix(computerName, prefix), computerName)
+	}
+	// Names sharing their last 15 characters get different computer names
+	assert.NotEqual(t, windowsComputerName("first-nodepool-abcde"), windowsComputerName("other-nodepool-abcde"))
+}
+
+func TestGetWindowsAdminPassword(t *testing.T) {
+	ctx := options.ToContext(context.Background(), &options.Options{SystemNamespace: "karpenter"})
+	p := &DefaultProvider{kubeClient: fake.NewClientset(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "karpenter"}, Data: map[string][]byte{"password": []byte("secret")}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "karpenter"}, Data: map[string][]byte{"password": {}}},
+	)}
+	password, err := p.getWindowsAdminPassword(ctx, "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", password.Value)
+	for _, name := range []string{"", "empty", "missing"} {
+		_, err := p.getWindowsAdminPassword(ctx, name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestGetWindowsCustomization(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		ctx = options.ToContext(ctx, &options.Options{SystemNamespace: "karpenter"})
+		p := newTestProvider(ctx, t, c)
+		p.kubeClient = fake.NewClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "karpenter"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		})
+		class := &v1alpha1.VsphereNodeClass{}
+		_, err := p.GetWindowsCustomization(ctx, class, "default-abcde")
+		assert.Error(t, err)
+
+		// the join command runs at the first logon
+		class.Spec.Windows = &v1alpha1.WindowsCustomization{AdminPasswordSecretName: "admin"}
+		spec, err := p.GetWindowsCustomization(ctx, class, "default-abcde")
+		require.NoError(t, err)
+		sysprep := spec.Identity.(*types.CustomizationSysprep)
+		assert.True(t, sysprep.GuiUnattended.AutoLogon)
+		assert.Equal(t, int32(1), sysprep.GuiUnattended.AutoLogonCount)
+		assert.Equal(t, []string{windowsJoinCommand}, sysprep.GuiRunOnce.CommandList)
+
+		// vcsim-windows-static has automatic logon disabled
+		class.Spec.Windows = &v1alpha1.WindowsCustomization{CustomizationSpec: "vcsim-windows-static"}
+		spec, err = p.GetWindowsCustomization(ctx, class, "default-abcde")
+		require.NoError(t, err)
+		sysprep = spec.Identity.(*types.CustomizationSysprep)
+		assert.True(t, sysprep.GuiUnattended.AutoLogon)
+		assert.GreaterOrEqual(t, sysprep.GuiUnattended.AutoLogonCount, int32(1))
+		assert.Contains(t, sysprep.GuiRunOnce.CommandList, windowsJoinCommand)
+	})
+}
@@ -0,0 +1,172 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/samber/lo"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+// selectedHosts returns the hosts matching the selector that belong to the
+// compute resource owning the resource pool.
+func (p *DefaultProvider) selectedHosts(ctx context.Context, selector v1alpha1.HostSelectorTerm, poolRef types.ManagedObjectReference) ([]mo.HostSystem, error) {
+	hosts, err := p.Finder.ResolveHosts(ctx, selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve hosts: %w", err)
+	}
+	owner, err := object.NewResourcePool(p.Finder.Client, poolRef).Owner(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource pool owner: %w", err)
+	}
+	refs := lo.Map(hosts, func(h *object.HostSystem, _ int) types.ManagedObjectReference { return h.Reference() })
+	var hostsMo []mo.HostSystem
+	err = property.DefaultCollector(p.Finder.Client).Retrieve(ctx, refs, []string{"name", "parent", "runtime", "summary.hardware", "summary.quickStats"}, &hostsMo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host properties: %w", err)
+	}
+	hostsMo = lo.Filter(hostsMo, func(h mo.HostSystem, _ int) bool {
+		return h.Parent != nil && *h.Parent == owner.Reference()
+	})
+	if len(hostsMo) == 0 {
+		return nil, fmt.Errorf("none of the selected hosts belong to compute resource %s", owner.Reference().Value)
+	}
+	return hostsMo, nil
+}
+
+// pickHost returns the connected host outside maintenance mode with the most
+// unused memory.
+func pickHost(hosts []mo.HostSystem) (*types.ManagedObjectReference, error) {
+	var picked *mo.HostSystem
+	var pickedFree int64
+	for i := range hosts {
+		h := &hosts[i]
+		if h.Runtime.ConnectionState != types.HostSystemConnectionStateConnected || h.Runtime.InMaintenanceMode {
+			continue
+		}
+		var free int64
+		if h.Summary.Hardware != nil {
+			free = h.Summary.Hardware.MemorySize/(1024*1024) - int64(h.Summary.QuickStats.OverallMemoryUsage)
+		}
+		if picked == nil || free > pickedFree {
+			picked, pickedFree = h, free
+		}
+	}
+	if picked == nil {
+		return nil, fmt.Errorf("no selected host is connected and out of maintenance mode")
+	}
+	ref := picked.Reference()
+	return &ref, nil
+}
+
+// hostGroupName is the prefix of the DRS groups and rule pinning the VMs of a NodeClass
+func hostGroupName(clusterName, nodeClass string) string {
+	return fmt.Sprintf("%s-karp-%s", clusterName, nodeClass)
+}
+
+// joinHostGroupRule keeps a DRS host group with the selected hosts, a VM group
+// and a mandatory VM-Host rule binding them for the NodeClass, and adds the VM
+// to the VM group. Destroyed VMs are dropped from the group by vCenter.
+func (p *DefaultProvider) joinHostGroupRule(ctx context.Context, class *v1alpha1.VsphereNodeClass, poolRef types.ManagedObjectReference, vm types.ManagedObjectReference) error {
+	cluster, err := p.poolCluster(ctx, poolRef)
+	if err != nil || cluster == nil {
+		return err
+	}
+	hosts, err := p.selectedHosts(ctx, *class.Spec.HostSelector, poolRef)
+	if err != nil {
+		return err
+	}
+	p.affinityMu.Lock()
+	defer p.affinityMu.Unlock()
+
+	config, err := cluster.Configuration(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster configuration: %w", err)
+	}
+	if config.DrsConfig.Enabled == nil || !*config.DrsConfig.Enabled {
+		// the VM is already placed on a selected host by the relocate spec
+		return nil
+	}
+	hostRefs := lo.Map(hosts, func(h mo.HostSystem, _ int) types.ManagedObjectReference { return h.Reference() })
+	spec := hostRuleSpec(config, hostGroupName(p.ClusterName, class.Name), hostRefs, vm)
+	if len(spec.GroupSpec) == 0 && len(spec.RulesSpec) == 0 {
+		return nil
+	}
+	task, err := cluster.Reconfigure(ctx, spec, true)
+	if err != nil {
+		return fmt.Errorf("failed to update DRS host group rule: %w", err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to update DRS host group rule: %w", err)
+	}
+	return nil
+}
+
+// hostRuleSpec returns the cluster changes bringing the host group, VM group
+// and VM-Host rule named after name up to date.
+func hostRuleSpec(config *types.ClusterConfigInfoEx, name string, hosts []types.ManagedObjectReference, vm types.ManagedObjectReference) *types.ClusterConfigSpecEx {
+	hostGroupName, vmGroupName := name+"-hosts", name+"-vms"
+	var hostGroup *types.ClusterHostGroup
+	var vmGroup *types.ClusterVmGroup
+	for _, g := range config.Group {
+		switch g := g.(type) {
+		case *types.ClusterHostGroup:
+			if g.Name == hostGroupName {
+				hostGroup = g
+			}
+		case *types.ClusterVmGroup:
+			if g.Name == vmGroupName {
+				vmGroup = g
+			}
+		}
+	}
+
+	spec := &types.ClusterConfigSpecEx{}
+	if hostGroup == nil || !lo.ElementsMatch(hostGroup.Host, hosts) {
+		spec.GroupSpec = append(spec.GroupSpec, types.ClusterGroupSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: lo.Ternary(hostGroup == nil, types.ArrayUpdateOperationAdd, types.ArrayUpdateOperationEdit)},
+			Info: &types.ClusterHostGroup{
+				ClusterGroupInfo: types.ClusterGroupInfo{Name: hostGroupName},
+				Host:             hosts,
+			},
+		})
+	}
+	if vmGroup == nil || !slices.Contains(vmGroup.Vm, vm) {
+		var vms []types.ManagedObjectReference
+		if vmGroup != nil {
+			vms = vmGroup.Vm
+		}
+		spec.GroupSpec = append(spec.GroupSpec, types.ClusterGroupSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: lo.Ternary(vmGroup == nil, types.ArrayUpdateOperationAdd, types.ArrayUpdateOperationEdit)},
+			Info: &types.ClusterVmGroup{
+				ClusterGroupInfo: types.ClusterGroupInfo{Name: vmGroupName},
+				Vm:               append(slices.Clone(vms), vm),
+			},
+		})
+	}
+	ruleExists := slices.ContainsFunc(config.Rule, func(r types.BaseClusterRuleInfo) bool {
+		rule, ok := r.(*types.ClusterVmHostRuleInfo)
+		return ok && rule.Name == name
+	})
+	if !ruleExists {
+		spec.RulesSpec = append(spec.RulesSpec, types.ClusterRuleSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+			Info: &types.ClusterVmHostRuleInfo{
+				ClusterRuleInfo: types.ClusterRuleInfo{
+					Name:      name,
+					Enabled:   lo.ToPtr(true),
+					Mandatory: lo.ToPtr(true),
+				},
+				VmGroupName:         vmGroupName,
+				AffineHostGroupName: hostGroupName,
+			},
+		})
+	}
+	return spec
+}
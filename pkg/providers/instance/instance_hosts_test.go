@@ -0,0 +1,71 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func testHost(name string, state types.HostSystemConnectionState, maintenance bool, memoryMB int64, usedMB int32) mo.HostSystem {
+	h := mo.HostSystem{
+		Runtime: types.HostRuntimeInfo{ConnectionState: state, InMaintenanceMode: maintenance},
+		Summary: types.HostListSummary{
+			Hardware:   &types.HostHardwareSummary{MemorySize: memoryMB * 1024 * 1024},
+			QuickStats: types.HostListSummaryQuickStats{OverallMemoryUsage: usedMB},
+		},
+	}
+	h.Self = types.ManagedObjectReference{Type: "HostSystem", Value: name}
+	return h
+}
+
+func TestPickHost(t *testing.T) {
+	host, err := pickHost([]mo.HostSystem{
+		testHost("busy", types.HostSystemConnectionStateConnected, false, 1024, 900),
+		testHost("maintenance", types.HostSystemConnectionStateConnected, true, 4096, 0),
+		testHost("disconnected", types.HostSystemConnectionStateDisconnected, false, 4096, 0),
+		testHost("free", types.HostSystemConnectionStateConnected, false, 1024, 100),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "free", host.Value)
+
+	_, err = pickHost([]mo.HostSystem{
+		testHost("maintenance", types.HostSystemConnectionStateConnected, true, 4096, 0),
+	})
+	assert.Error(t, err)
+}
+
+func TestHostRuleSpec(t *testing.T) {
+	host1 := types.ManagedObjectReference{Type: "HostSystem", Value: "host-1"}
+	host2 := types.ManagedObjectReference{Type: "HostSystem", Value: "host-2"}
+	vm1 := types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1"}
+	vm2 := types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-2"}
+
+	spec := hostRuleSpec(&types.ClusterConfigInfoEx{}, "pool", []types.ManagedObjectReference{host1}, vm1)
+	assert.Len(t, spec.GroupSpec, 2)
+	assert.Equal(t, types.ArrayUpdateOperationAdd, spec.GroupSpec[0].Operation)
+	assert.Len(t, spec.RulesSpec, 1)
+	rule := spec.RulesSpec[0].Info.(*types.ClusterVmHostRuleInfo)
+	assert.Equal(t, "pool-vms", rule.VmGroupName)
+	assert.Equal(t, "pool-hosts", rule.AffineHostGroupName)
+	assert.True(t, *rule.Mandatory)
+
+	config := &types.ClusterConfigInfoEx{
+		Group: []types.BaseClusterGroupInfo{
+			&types.ClusterHostGroup{ClusterGroupInfo: types.ClusterGroupInfo{Name: "pool-hosts"}, Host: []types.ManagedObjectReference{host2, host1}},
+			&types.ClusterVmGroup{ClusterGroupInfo: types.ClusterGroupInfo{Name: "pool-vms"}, Vm: []types.ManagedObjectReference{vm1}},
+		},
+		Rule: []types.BaseClusterRuleInfo{
+			&types.ClusterVmHostRuleInfo{ClusterRuleInfo: types.ClusterRuleInfo{Name: "pool"}},
+		},
+	}
+	spec = hostRuleSpec(config, "pool", []types.ManagedObjectReference{host1, host2}, vm1)
+	assert.Empty(t, spec.GroupSpec)
+	assert.Empty(t, spec.RulesSpec)
+
+	spec = hostRuleSpec(config, "pool", []types.ManagedObjectReference{host1, host2}, vm2)
+	assert.Len(t, spec.GroupSpec, 1)
+	assert.Equal(t, types.ArrayUpdateOperationEdit, spec.GroupSpec[0].Operation)
+	assert.Equal(t, []types.ManagedObjectReference{vm1, vm2}, spec.GroupSpec[0].Info.(*types.ClusterVmGroup).Vm)
+}
@@ -1,12 +1,21 @@
 package instance
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
 	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/userdata"
+	"github.com/samber/lo"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
 )
 
 // Config is data used with a VM's guestInfo RPC interface.
@@ -19,8 +28,20 @@ const (
 	guestInfoUserDataEncoding = "guestinfo.userdata.encoding"
 	guestInfoMetadata         = "guestinfo.metadata"
 	guestInfoMetadataEncoding = "guestinfo.metadata.encoding"
+	// clusterMarkerKey records the cluster of the VMs created by the provider,
+	// so VMs whose tags failed to attach are still listed and garbage collected
+	clusterMarkerKey = "karpenter.vsphere.cluster"
 )
 
+// managedExtraConfigPrefixes are the keys set by the provider, they can't be
+// overridden through the NodeClass extraConfig.
+var managedExtraConfigPrefixes = []string{
+	guestInfoUserData,
+	guestInfoMetadata,
+	"guestinfo.ignition.",
+	clusterMarkerKey,
+}
+
 func (e *Config) Extract() []types.BaseOptionValue {
 	if e == nil {
 		return nil
@@ -51,10 +72,13 @@ func (p *DefaultProvider) GetInitData(initData *userdata.InitData, initType *use
 		initData.AdditionalUserData,
 	)
 
-	switch initType.Format {
-	case v1alpha1.UserDataTypeIgnition:
+	switch {
+	case initType.OS == string(corev1.Windows):
+		// Read by the Sysprep run once command, see windowsJoinCommand
+		configData.SetPowerShellUserData(result)
+	case initType.Format == v1alpha1.UserDataTypeIgnition:
 		configData.SetIgnitionUserData(result)
-	case v1alpha1.UserDataTypeCloudConfig:
+	case initType.Format == v1alpha1.UserDataTypeCloudConfig:
 		configData.SetCloudConfigUserData(result)
 	default:
 		return nil, fmt.Errorf("unsupported user data format: %s", initType.Format)
@@ -95,6 +119,12 @@ func (e *Config) SetCloudConfigUserData(data []byte) {
 	e.setData(guestInfoUserData, guestInfoUserDataEncoding, data)
 }
 
+// SetPowerShellUserData sets the PowerShell join script at the key
+// "guestinfo.userdata" as a base64-encoded string.
+func (e *Config) SetPowerShellUserData(data []byte) {
+	e.setData(guestInfoUserData, guestInfoUserDataEncoding, data)
+}
+
 // encode first attempts to decode the data as many times as necessary
 // to ensure it is plain-text before returning the result as a base64
 // encoded string.
@@ -111,3 +141,62 @@ func (e *Config) encode(data []byte) string {
 	}
 	return base64.StdEncoding.EncodeToString(data)
 }
+
+// ValidateExtraConfig returns an error when the extraConfig sets a key managed by the provider.
+func ValidateExtraConfig(extraConfig map[string]string) error {
+	for key := range extraConfig {
+		for _, prefix := range managedExtraConfigPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return fmt.Errorf("extraConfig key %s is managed by the provider", key)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeExtraConfig merges the NodeClass extraConfig into the generated options,
+// user supplied values replace generated options with the same key.
+func mergeExtraConfig(generated []types.BaseOptionValue, extraConfig map[string]string) ([]types.BaseOptionValue, error) {
+	if err := ValidateExtraConfig(extraConfig); err != nil {
+		return nil, err
+	}
+	merged := lo.Reject(generated, func(o types.BaseOptionValue, _ int) bool {
+		_, ok := extraConfig[o.GetOptionValue().Key]
+		return ok
+	})
+	// Sorted to keep the generated spec stable between launches
+	for _, key := range slices.Sorted(maps.Keys(extraConfig)) {
+		merged = append(merged, &types.OptionValue{
+			Key:   key,
+			Value: extraConfig[key],
+		})
+	}
+	return merged, nil
+}
+
+// clusterMarker returns the extraConfig option marking a VM of the cluster
+func clusterMarker(clusterName string) types.BaseOptionValue {
+	return &types.OptionValue{Key: clusterMarkerKey, Value: clusterName}
+}
+
+// markedVMs returns the VMs carrying the marker of the cluster, their
+// extraConfig is only read for the few VMs missing the cluster tag
+func markedVMs(ctx context.Context, c *vim25.Client, refs []types.ManagedObjectReference, clusterName string) (map[types.ManagedObjectReference]bool, error) {
+	marked := map[types.ManagedObjectReference]bool{}
+	if len(refs) == 0 {
+		return marked, nil
+	}
+	var vms []mo.VirtualMachine
+	if err := property.DefaultCollector(c).Retrieve(ctx, refs, []string{"config.extraConfig"}, &vms); err != nil {
+		return nil, fmt.Errorf("failed to get VM extraConfig: %w", err)
+	}
+	for _, vm := range vms {
+		if vm.Config == nil {
+			continue
+		}
+		marked[vm.Self] = lo.ContainsBy(vm.Config.ExtraConfig, func(o types.BaseOptionValue) bool {
+			return o.GetOptionValue().Key == clusterMarkerKey && o.GetOptionValue().Value == clusterName
+		})
+	}
+	return marked, nil
+}
@@ -0,0 +1,71 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestMergeExtraConfig(t *testing.T) {
+	generated := []types.BaseOptionValue{
+		&types.OptionValue{Key: numaMaxVCPUsPerNode, Value: "16"},
+		&types.OptionValue{Key: numaMinVCPUs, Value: "9"},
+	}
+	merged, err := mergeExtraConfig(generated, map[string]string{
+		"time.synchronize.tools.startup": "FALSE",
+		numaMaxVCPUsPerNode:              "8",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.BaseOptionValue{
+		&types.OptionValue{Key: numaMinVCPUs, Value: "9"},
+		&types.OptionValue{Key: numaMaxVCPUsPerNode, Value: "8"},
+		&types.OptionValue{Key: "time.synchronize.tools.startup", Value: "FALSE"},
+	}, merged)
+}
+
+func TestValidateExtraConfig(t *testing.T) {
+	for _, key := range []string{
+		guestInfoUserData,
+		guestInfoUserDataEncoding,
+		guestInfoMetadata,
+		guestInfoIgnitionData,
+		guestInfoIgnitionEncoding,
+		clusterMarkerKey,
+	} {
+		assert.Error(t, ValidateExtraConfig(map[string]string{key: "foo"}), key)
+	}
+	assert.NoError(t, ValidateExtraConfig(map[string]string{
+		"isolation.tools.copy.disable": "TRUE",
+		"guestinfo.custom":             "bar",
+	}))
+}
+
+func TestMarkedVMs(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		findClient := find.NewFinder(c, true)
+		vms, err := findClient.VirtualMachineList(ctx, "/DC0/vm/DC0_H0_VM*")
+		require.NoError(t, err)
+		require.Len(t, vms, 2)
+		for i, cluster := range []string{"test", "other"} {
+			task, err := vms[i].Reconfigure(ctx, types.VirtualMachineConfigSpec{ExtraConfig: []types.BaseOptionValue{clusterMarker(cluster)}})
+			require.NoError(t, err)
+			require.NoError(t, task.Wait(ctx))
+		}
+		refs := []types.ManagedObjectReference{vms[0].Reference(), vms[1].Reference()}
+
+		marked, err := markedVMs(ctx, c, refs, "test")
+		require.NoError(t, err)
+		assert.True(t, marked[vms[0].Reference()])
+		assert.False(t, marked[vms[1].Reference()], "marked for another cluster")
+
+		marked, err = markedVMs(ctx, c, nil, "test")
+		assert.NoError(t, err)
+		assert.Empty(t, marked)
+	})
+}
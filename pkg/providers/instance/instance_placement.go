@@ -0,0 +1,118 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// HostPlacementTTL is how long the host and cluster names of an ESXi host are
+// reused, hosts rarely change cluster while DRS migrates VMs all the time
+const HostPlacementTTL = 10 * time.Minute
+
+// hostPlacementCache remembers the placement of the ESXi hosts, so looking up
+// where a VM runs costs a single call for its host
+type hostPlacementCache struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	byHost map[types.ManagedObjectReference]cachedPlacement
+}
+
+type cachedPlacement struct {
+	placement
+	expires time.Time
+}
+
+func newHostPlacementCache() *hostPlacementCache {
+	return &hostPlacementCache{now: time.Now, byHost: map[types.ManagedObjectReference]cachedPlacement{}}
+}
+
+// get returns the placement of the host, looking it up when not cached
+func (c *hostPlacementCache) get(ctx context.Context, client *vim25.Client, host types.ManagedObjectReference) (placement, error) {
+	c.mu.Lock()
+	cached, ok := c.byHost[host]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.placement, nil
+	}
+	placements, err := hostPlacements(ctx, client, []types.ManagedObjectReference{host})
+	if err != nil {
+		return placement{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byHost[host] = cachedPlacement{placement: placements[host], expires: c.now().Add(HostPlacementTTL)}
+	return placements[host], nil
+}
+
+// withPlacement records where the VM runs on the instance, lookup failures
+// only leave the placement empty.
+func (p *DefaultProvider) withPlacement(ctx context.Context, i *Instance) *Instance {
+	var vmMo mo.VirtualMachine
+	if err := i.vm.Properties(ctx, i.vm.Reference(), []string{"runtime.host"}, &vmMo); err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf("failed to get host of VM %s", i.Name))
+		return i
+	}
+	i.Host, i.ComputeCluster = p.hostPlacement(ctx, vmMo.Runtime.Host, i.Name).unpack()
+	return i
+}
+
+// hostPlacement returns the placement of the host running the VM, empty when
+// the VM has no host or the lookup failed
+func (p *DefaultProvider) hostPlacement(ctx context.Context, host *types.ManagedObjectReference, vmName string) placement {
+	if host == nil {
+		return placement{}
+	}
+	hostPlacement, err := p.hosts.get(ctx, p.Finder.Client, *host)
+	if err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf("failed to get placement for VM %s", vmName))
+	}
+	return hostPlacement
+}
+
+type placement struct {
+	host    string
+	cluster string
+}
+
+func (p placement) unpack() (string, string) {
+	return p.host, p.cluster
+}
+
+// hostPlacements returns the host and cluster names of the hosts, retrieving
+// every host and cluster once
+func hostPlacements(ctx context.Context, c *vim25.Client, hostRefs []types.ManagedObjectReference) (map[types.ManagedObjectReference]placement, error) {
+	placements := map[types.ManagedObjectReference]placement{}
+	if len(hostRefs) == 0 {
+		return placements, nil
+	}
+	pc := property.DefaultCollector(c)
+	var hosts []mo.HostSystem
+	if err := pc.Retrieve(ctx, lo.Uniq(hostRefs), []string{"name", "parent"}, &hosts); err != nil {
+		return placements, fmt.Errorf("failed to get host properties: %w", err)
+	}
+	clusterRefs := lo.FilterMap(hosts, func(host mo.HostSystem, _ int) (types.ManagedObjectReference, bool) {
+		return lo.FromPtr(host.Parent), host.Parent != nil && host.Parent.Type == "ClusterComputeResource"
+	})
+	var clusters []mo.ClusterComputeResource
+	if len(clusterRefs) > 0 {
+		if err := pc.Retrieve(ctx, lo.Uniq(clusterRefs), []string{"name"}, &clusters); err != nil {
+			return placements, fmt.Errorf("failed to get cluster properties: %w", err)
+		}
+	}
+	clusterNames := lo.SliceToMap(clusters, func(cluster mo.ClusterComputeResource) (types.ManagedObjectReference, string) {
+		return cluster.Self, cluster.Name
+	})
+	for _, host := range hosts {
+		placements[host.Self] = placement{host: host.Name, cluster: clusterNames[lo.FromPtr(host.Parent)]}
+	}
+	return placements, nil
+}
@@ -0,0 +1,76 @@
+package instance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestHostPlacements(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		findClient := find.NewFinder(c, true)
+		standalone, err := findClient.HostSystem(ctx, "/DC0/host/DC0_H0/DC0_H0")
+		require.NoError(t, err)
+		clustered, err := findClient.HostSystem(ctx, "/DC0/host/DC0_C0/DC0_C0_H0")
+		require.NoError(t, err)
+
+		refs := []types.ManagedObjectReference{standalone.Reference(), clustered.Reference(), clustered.Reference()}
+		placements, err := hostPlacements(ctx, c, refs)
+		require.NoError(t, err)
+		assert.Equal(t, placement{host: "DC0_H0"}, placements[standalone.Reference()])
+		assert.Equal(t, placement{host: "DC0_C0_H0", cluster: "DC0_C0"}, placements[clustered.Reference()])
+
+		placements, err = hostPlacements(ctx, c, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, placements)
+	})
+}
+
+func TestHostPlacementCache(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		findClient := find.NewFinder(c, true)
+		host, err := findClient.HostSystem(ctx, "/DC0/host/DC0_C0/DC0_C0_H0")
+		require.NoError(t, err)
+		now := time.Now()
+		cache := newHostPlacementCache()
+		cache.now = func() time.Time { return now }
+
+		hostPlacement, err := cache.get(ctx, c, host.Reference())
+		require.NoError(t, err)
+		assert.Equal(t, placement{host: "DC0_C0_H0", cluster: "DC0_C0"}, hostPlacement)
+
+		cluster, err := findClient.ClusterComputeResource(ctx, "/DC0/host/DC0_C0")
+		require.NoError(t, err)
+		task, err := cluster.Rename(ctx, "cluster-a")
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		hostPlacement, err = cache.get(ctx, c, host.Reference())
+		require.NoError(t, err)
+		assert.Equal(t, "DC0_C0", hostPlacement.cluster, "served from the cache")
+
+		now = now.Add(HostPlacementTTL + time.Second)
+		hostPlacement, err = cache.get(ctx, c, host.Reference())
+		require.NoError(t, err)
+		assert.Equal(t, "cluster-a", hostPlacement.cluster, "looked up again once expired")
+	})
+}
+
+func TestGetPlacement(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTestProvider(ctx, t, c)
+		vm, err := find.NewFinder(c, true).VirtualMachine(ctx, "/DC0/vm/DC0_C0_RP0_VM0")
+		require.NoError(t, err)
+		instance, err := p.Get(ctx, vm.UUID(ctx))
+		require.NoError(t, err)
+		assert.Equal(t, "DC0_C0_RP0_VM0", instance.Name)
+		assert.Equal(t, "DC0_C0", instance.ComputeCluster)
+		assert.NotEmpty(t, instance.Host)
+	})
+}
@@ -0,0 +1,61 @@
+package instance
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/vmware/govmomi/object"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/session"
+)
+
+// TaskLimiter caps the long-running tasks, e.g. clone, power and destroy, the
+// providers of a vCenter run at once. A nil TaskLimiter doesn't cap them.
+type TaskLimiter struct {
+	vcenter string
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+// NewTaskLimiter returns a limiter running up to maxTasks tasks at once, nil
+// when maxTasks is 0
+func NewTaskLimiter(vcenter string, maxTasks int) *TaskLimiter {
+	if maxTasks <= 0 {
+		return nil
+	}
+	return &TaskLimiter{vcenter: vcenter, slots: make(chan struct{}, maxTasks)}
+}
+
+// run starts the task once a slot is free and keeps the slot until the task
+// completes
+func (l *TaskLimiter) run(ctx context.Context, start func() (*object.Task, error)) error {
+	release, err := l.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	task, err := start()
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+// acquire blocks until a slot is free, the returned func frees it
+func (l *TaskLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.setDepth(l.waiting.Add(1))
+	defer func() { l.setDepth(l.waiting.Add(-1)) }()
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *TaskLimiter) setDepth(depth int64) {
+	session.APIQueueDepth.Set(float64(depth), map[string]string{"vcenter": l.vcenter, "queue": session.TaskQueue})
+}
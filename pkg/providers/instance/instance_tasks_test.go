@@ -0,0 +1,51 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestTaskLimiterAcquire(t *testing.T) {
+	assert.Nil(t, NewTaskLimiter("vcenter", 0))
+	release, err := (*TaskLimiter)(nil).acquire(context.Background())
+	require.NoError(t, err)
+	release()
+
+	l := NewTaskLimiter("vcenter", 1)
+	release, err = l.acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = l.acquire(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	release()
+	release, err = l.acquire(context.Background())
+	assert.NoError(t, err)
+	release()
+}
+
+func TestTaskLimiterRun(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		vm, err := find.NewFinder(c, true).VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		require.NoError(t, err)
+		l := NewTaskLimiter("vcenter", 1)
+		require.NoError(t, l.run(ctx, func() (*object.Task, error) { return vm.PowerOff(ctx) }))
+		state, err := vm.PowerState(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, types.VirtualMachinePowerStatePoweredOff, state)
+
+		// the slot is freed once the task completes, failed or not
+		assert.Error(t, l.run(ctx, func() (*object.Task, error) { return vm.PowerOff(ctx) }))
+		assert.NoError(t, l.run(ctx, func() (*object.Task, error) { return vm.PowerOn(ctx) }))
+	})
+}
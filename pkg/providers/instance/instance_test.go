@@ -0,0 +1,104 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/providers/finder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func newTestProvider(ctx context.Context, t *testing.T, c *vim25.Client) *DefaultProvider {
+	restClient := rest.NewClient(c)
+	require.NoError(t, restClient.Login(ctx, simulator.DefaultLogin))
+	findClient := find.NewFinder(c, true)
+	dc, err := findClient.Datacenter(ctx, "DC0")
+	require.NoError(t, err)
+	return NewDefaultProvider(nil, finder.NewDefaultProvider(tags.NewManager(restClient), c, findClient, dc, "karpenter", "DC0"), "DC0")
+}
+
+func TestDelete(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTestProvider(ctx, t, c)
+		findClient := find.NewFinder(c, true)
+		// launched VMs carry the template they were cloned from
+		vms, err := findClient.VirtualMachineList(ctx, "/DC0/vm/*")
+		require.NoError(t, err)
+		for _, vm := range vms {
+			task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{Annotation: "cloned_from:template"})
+			require.NoError(t, err)
+			require.NoError(t, task.Wait(ctx))
+		}
+		cluster, err := findClient.ClusterComputeResource(ctx, "/DC0/host/DC0_C0")
+		require.NoError(t, err)
+		vm0, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_C0_RP0_VM0")
+		require.NoError(t, err)
+		vm1, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_C0_RP0_VM1")
+		require.NoError(t, err)
+		rule := antiAffinityRuleName("DC0", "default")
+		require.NoError(t, reconfigureRule(ctx, cluster, antiAffinityRuleSpec(nil, rule, false, []types.ManagedObjectReference{vm0.Reference(), vm1.Reference()})))
+		require.NoError(t, p.Finder.TagInstance(ctx, vm0.Reference(), map[string]string{v1alpha1.AntiAffinityTagKey: rule}))
+
+		require.NoError(t, p.Delete(ctx, vm0.UUID(ctx)))
+		config, err := cluster.Configuration(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, findAntiAffinityRule(config, rule), "the rule is dropped with less than two VMs")
+		_, err = findClient.VirtualMachine(ctx, "/DC0/vm/DC0_C0_RP0_VM0")
+		assert.Error(t, err)
+
+		// VMs without a rule are destroyed without looking at DRS rules
+		standalone, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		require.NoError(t, err)
+		require.NoError(t, p.Delete(ctx, standalone.UUID(ctx)))
+		_, err = findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		assert.Error(t, err)
+	})
+}
+
+func TestDiscardVM(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		findClient := find.NewFinder(c, true)
+		vm, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM1")
+		require.NoError(t, err)
+		task, err := vm.PowerOff(ctx)
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+
+		(&DefaultProvider{}).discardVM(ctx, vm)
+		_, err = findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM1")
+		assert.Error(t, err)
+	})
+}
+
+func TestTagVM(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		p := newTestProvider(ctx, t, c)
+		findClient := find.NewFinder(c, true)
+		vm, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		require.NoError(t, err)
+		require.NoError(t, p.tagVM(ctx, vm, map[string]string{v1alpha1.ClusterNameTagKey: "karpenter"}))
+		_, err = findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		assert.NoError(t, err)
+
+		// the clone is still powered off when tagging fails
+		vm, err = findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM1")
+		require.NoError(t, err)
+		task, err := vm.PowerOff(ctx)
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		require.NoError(t, p.Finder.TagManager.Logout(ctx))
+		assert.Error(t, p.tagVM(ctx, vm, map[string]string{v1alpha1.ClusterNameTagKey: "other"}))
+		_, err = findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM1")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,47 @@
+package instance
+
+import (
+	"fmt"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/samber/lo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	numaMaxVCPUsPerNode = "numa.vcpu.maxPerVirtualNode"
+	numaMinVCPUs        = "numa.vcpu.min"
+)
+
+// applyTopology sets the socket layout, hot add flags and vNUMA advanced
+// settings on the clone config.
+func applyTopology(config *types.VirtualMachineConfigSpec, topology *v1alpha1.CPUTopology) error {
+	if topology == nil {
+		return nil
+	}
+	if topology.CoresPerSocket > 0 {
+		if config.NumCPUs%topology.CoresPerSocket != 0 {
+			return fmt.Errorf("%d vCPUs can't be split into sockets of %d cores", config.NumCPUs, topology.CoresPerSocket)
+		}
+		config.NumCoresPerSocket = topology.CoresPerSocket
+	}
+	if topology.CPUHotAdd {
+		config.CpuHotAddEnabled = lo.ToPtr(true)
+	}
+	if topology.MemoryHotAdd {
+		config.MemoryHotAddEnabled = lo.ToPtr(true)
+	}
+	if topology.MaxVCPUsPerNUMANode > 0 {
+		config.ExtraConfig = append(config.ExtraConfig, &types.OptionValue{
+			Key:   numaMaxVCPUsPerNode,
+			Value: fmt.Sprint(topology.MaxVCPUsPerNUMANode),
+		})
+	}
+	if topology.NUMAMinVCPUs > 0 {
+		config.ExtraConfig = append(config.ExtraConfig, &types.OptionValue{
+			Key:   numaMinVCPUs,
+			Value: fmt.Sprint(topology.NUMAMinVCPUs),
+		})
+	}
+	return nil
+}
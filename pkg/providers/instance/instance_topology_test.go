@@ -0,0 +1,28 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+func TestApplyTopology(t *testing.T) {
+	config := &types.VirtualMachineConfigSpec{NumCPUs: 32}
+	err := applyTopology(config, &v1alpha1.CPUTopology{
+		CoresPerSocket:      16,
+		MaxVCPUsPerNUMANode: 16,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(16), config.NumCoresPerSocket)
+	assert.Nil(t, config.CpuHotAddEnabled)
+	assert.Equal(t, []types.BaseOptionValue{
+		&types.OptionValue{Key: numaMaxVCPUsPerNode, Value: "16"},
+	}, config.ExtraConfig)
+
+	config = &types.VirtualMachineConfigSpec{NumCPUs: 6}
+	err = applyTopology(config, &v1alpha1.CPUTopology{CoresPerSocket: 4})
+	assert.ErrorContains(t, err, "can't be split into sockets")
+}
@@ -0,0 +1,183 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	corecloudprovider "sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+)
+
+var _ Provider = (*Router)(nil)
+
+// Router routes instance operations to the provider of the vCenter selected by
+// the NodeClass, or of the vCenter the VM lives in
+type Router struct {
+	defaultProvider *DefaultProvider
+	providers       map[string]*DefaultProvider
+
+	mu sync.RWMutex
+	// owners maps the UUIDs of the VMs seen to the vCenter owning them
+	owners map[string]string
+	// listed holds the last VMs listed in each vCenter
+	listed map[string][]*Instance
+}
+
+// NewRouter returns the default provider alone when there are no other vCenters
+func NewRouter(defaultProvider *DefaultProvider, providers map[string]*DefaultProvider) Provider {
+	if len(providers) == 0 {
+		return defaultProvider
+	}
+	return &Router{
+		defaultProvider: defaultProvider,
+		providers:       providers,
+		owners:          map[string]string{},
+		listed:          map[string][]*Instance{},
+	}
+}
+
+// all returns the default provider followed by the others ordered by name
+func (r *Router) all() []*DefaultProvider {
+	names := lo.Keys(r.providers)
+	slices.Sort(names)
+	return append([]*DefaultProvider{r.defaultProvider}, lo.Map(names, func(name string, _ int) *DefaultProvider { return r.providers[name] })...)
+}
+
+func (r *Router) Create(ctx context.Context, class *v1alpha1.VsphereNodeClass, claim *karpv1.NodeClaim, instanceTypes []*corecloudprovider.InstanceType) (*Instance, error) {
+	if class.Spec.VCenter == "" || class.Spec.VCenter == r.defaultProvider.VCenter {
+		i, err := r.defaultProvider.Create(ctx, class, claim, instanceTypes)
+		if err != nil {
+			return nil, err
+		}
+		r.record(r.defaultProvider, i)
+		return i, nil
+	}
+	p, ok := r.providers[class.Spec.VCenter]
+	if !ok {
+		return nil, fmt.Errorf("unknown vCenter %s", class.Spec.VCenter)
+	}
+	i, err := p.Create(ctx, class, claim, instanceTypes)
+	if err != nil {
+		return nil, err
+	}
+	r.record(p, i)
+	return i, nil
+}
+
+// provider returns the provider of the vCenter, nil when it is unknown
+func (r *Router) provider(vCenter string) *DefaultProvider {
+	if vCenter == "" || vCenter == r.defaultProvider.VCenter {
+		return r.defaultProvider
+	}
+	return r.providers[vCenter]
+}
+
+// record remembers the vCenter owning the VM, as recorded in its tag
+func (r *Router) record(p *DefaultProvider, i *Instance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owners[i.ID] = lo.CoalesceOrEmpty(i.Tags[v1alpha1.VCenterTagKey], p.VCenter)
+}
+
+func (r *Router) forget(vmID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.owners, vmID)
+}
+
+// owner returns the provider of the vCenter holding the VM. Provider IDs don't
+// carry the vCenter, so it is taken from the vCenter tag of the VMs seen by
+// Create, Get and List, then from the caches. VMs seen by none of them are
+// looked up in the vCenters whose cache isn't synced.
+func (r *Router) owner(ctx context.Context, vmID string) (*DefaultProvider, *Instance, error) {
+	r.mu.RLock()
+	vCenter, ok := r.owners[vmID]
+	r.mu.RUnlock()
+	if p := r.provider(vCenter); ok && p != nil {
+		i, err := p.Get(ctx, vmID)
+		if corecloudprovider.IsNodeClaimNotFoundError(err) {
+			r.forget(vmID)
+		}
+		return p, i, err
+	}
+	for _, p := range r.all() {
+		if i, ok := p.Cache.get(vmID); ok {
+			r.record(p, i)
+			return p, i, nil
+		}
+	}
+	var errs []error
+	for _, p := range r.all() {
+		// a synced cache holds every VM of the cluster in its vCenter
+		if p.Cache.Synced() {
+			continue
+		}
+		i, err := p.Get(ctx, vmID)
+		if err == nil {
+			r.record(p, i)
+			return p, i, nil
+		}
+		if !corecloudprovider.IsNodeClaimNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("vCenter %s: %w", p.VCenter, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("failed to find VM %s: %w", vmID, errors.Join(errs...))
+	}
+	return nil, nil, corecloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("VM %s not found in any vCenter", vmID))
+}
+
+func (r *Router) Get(ctx context.Context, vmID string) (*Instance, error) {
+	_, i, err := r.owner(ctx, vmID)
+	return i, err
+}
+
+// List returns the VMs of every vCenter. An unreachable vCenter contributes
+// the VMs it listed last, so its NodeClaims are not garbage collected as if
+// their VMs were gone. The list fails only when such a vCenter was never listed.
+func (r *Router) List(ctx context.Context) ([]*Instance, error) {
+	var instances []*Instance
+	var errs []error
+	for _, p := range r.all() {
+		found, err := p.List(ctx)
+		r.mu.Lock()
+		if err == nil {
+			r.listed[p.VCenter] = found
+		} else if last, ok := r.listed[p.VCenter]; ok {
+			log.FromContext(ctx).Error(err, fmt.Sprintf("failed to list VMs of vCenter %s, using the last list", p.VCenter))
+			found, err = last, nil
+		}
+		r.mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vCenter %s: %w", p.VCenter, err))
+			continue
+		}
+		for _, i := range found {
+			r.record(p, i)
+		}
+		instances = append(instances, found...)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to list VMs: %w", errors.Join(errs...))
+	}
+	return instances, nil
+}
+
+func (r *Router) Delete(ctx context.Context, vmID string) error {
+	p, _, err := r.owner(ctx, vmID)
+	if err != nil {
+		return err
+	}
+	if err := p.Delete(ctx, vmID); err != nil {
+		return err
+	}
+	r.forget(vmID)
+	return nil
+}
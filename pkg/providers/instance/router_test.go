@@ -0,0 +1,67 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestRouterUnreachableVCenter(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		model := simulator.VPX()
+		require.NoError(t, model.Create())
+		defer model.Remove()
+		model.Service.RegisterEndpoints = true
+		server := model.Service.NewServer()
+		other, err := govmomi.NewClient(ctx, server.URL, true)
+		require.NoError(t, err)
+
+		a, b := newTestProvider(ctx, t, c), newTestProvider(ctx, t, other.Client)
+		a.VCenter, b.VCenter = "a", "b"
+		findClient := find.NewFinder(c, true)
+		dc, err := findClient.Datacenter(ctx, "DC0")
+		require.NoError(t, err)
+		folders, err := dc.Folders(ctx)
+		require.NoError(t, err)
+		folder, err := folders.VmFolder.CreateFolder(ctx, "karpenter")
+		require.NoError(t, err)
+		vm, err := findClient.VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+		require.NoError(t, err)
+		task, err := folder.MoveInto(ctx, []types.ManagedObjectReference{vm.Reference()})
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		task, err = vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{Annotation: "cloned_from:template"})
+		require.NoError(t, err)
+		require.NoError(t, task.Wait(ctx))
+		require.NoError(t, a.Finder.TagInstance(ctx, vm.Reference(), map[string]string{v1alpha1.ClusterNameTagKey: "DC0", v1alpha1.VCenterTagKey: "a"}))
+
+		r := NewRouter(a, map[string]*DefaultProvider{"b": b})
+		instances, err := r.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+
+		unlisted := NewRouter(a, map[string]*DefaultProvider{"b": b})
+		server.Close()
+		instances, err = r.List(ctx)
+		require.NoError(t, err, "vCenter b contributes its last list")
+		assert.Len(t, instances, 1)
+		_, err = unlisted.List(ctx)
+		assert.Error(t, err, "the VMs of vCenter b were never listed")
+
+		// the VM is routed to its vCenter without asking vCenter b
+		instance, err := r.Get(ctx, vm.UUID(ctx))
+		require.NoError(t, err)
+		assert.Equal(t, "a", instance.Tags[v1alpha1.VCenterTagKey])
+		require.NoError(t, r.Delete(ctx, vm.UUID(ctx)))
+		_, err = findClient.VirtualMachine(ctx, "/DC0/vm/karpenter/DC0_H0_VM0")
+		assert.Error(t, err)
+	})
+}
@@ -1,7 +1,6 @@
 package instance
 
 import (
-	"context"
 	"time"
 
 	v1alpha1 "github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
@@ -16,13 +15,12 @@ type Instance struct {
 	Name       string
 	Type       string
 	Tags       map[string]string
-	vm         *object.VirtualMachine
+	// Host and ComputeCluster are the names of the ESXi host and cluster the VM runs on
+	Host           string
+	ComputeCluster string
+	vm             *object.VirtualMachine
 }
 
-func NewInstanceFromVM(ctx context.Context, vm *object.VirtualMachine, created time.Time, tags map[string]string) *Instance {
-	instance := NewInstance(vm, vm.UUID(ctx), getImageFromAnnotation(vm), "", vm.Name(), created, tags)
-	return instance
-}
 func NewInstance(vm *object.VirtualMachine, id, image, state, name string, created time.Time, tags map[string]string) *Instance {
 	return &Instance{
 		LaunchTime: created,
@@ -0,0 +1,126 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/vmware/govmomi/fault"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// restSessionHeader carries the REST session id, see vapi/internal.SessionCookieName
+const restSessionHeader = "vmware-api-session-id"
+
+type reloginKey struct{}
+
+// withoutRelogin marks calls made while logging in, so an authentication
+// failure of the login itself is returned instead of retried
+func withoutRelogin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, reloginKey{}, true)
+}
+
+func reloginAllowed(ctx context.Context) bool {
+	return ctx.Value(reloginKey{}) == nil
+}
+
+// Check reports whether both the SOAP and the REST sessions are authenticated.
+// It is meant to be registered as a health check of the controller.
+func (s *Session) Check(req *http.Request) error {
+	return s.active(req.Context())
+}
+
+func (s *Session) active(ctx context.Context) error {
+	userSession, err := session.NewManager(s.Client).UserSession(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get vCenter session: %w", err)
+	}
+	if userSession == nil {
+		return errors.New("vCenter session is not authenticated")
+	}
+	restSession, err := s.REST.Session(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get vCenter REST session: %w", err)
+	}
+	if restSession == nil {
+		return errors.New("vCenter REST session is not authenticated")
+	}
+	return nil
+}
+
+// relogin logs in with the last credentials unless another caller already did
+func (s *Session) relogin(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx = withoutRelogin(ctx)
+	if s.active(ctx) == nil {
+		return nil
+	}
+	return s.login(ctx, s.user)
+}
+
+// reloginSOAP retries a SOAP call once after logging in again when vCenter
+// rejects it with NotAuthenticated
+type reloginSOAP struct {
+	session      *Session
+	roundTripper soap.RoundTripper
+}
+
+func (r *reloginSOAP) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	err := r.roundTripper.RoundTrip(ctx, req, res)
+	if err == nil || !reloginAllowed(ctx) || !fault.Is(err, &types.NotAuthenticated{}) {
+		return err
+	}
+	if loginErr := r.session.relogin(ctx); loginErr != nil {
+		return fmt.Errorf("%w: %w", err, loginErr)
+	}
+	// the fault of the first attempt stays in the response body unless cleared
+	body := reflect.ValueOf(res).Elem()
+	body.Set(reflect.Zero(body.Type()))
+	return r.roundTripper.RoundTrip(ctx, req, res)
+}
+
+// reloginREST retries a REST request once after logging in again when vCenter
+// rejects it as unauthorized
+type reloginREST struct {
+	session      *Session
+	roundTripper http.RoundTripper
+}
+
+func (r *reloginREST) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.GetBody == nil {
+		// keep the body so the request can be sent again
+		req = req.Clone(req.Context())
+		payload, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(payload))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(payload)), nil
+		}
+	}
+	res, err := r.roundTripper.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized || !reloginAllowed(req.Context()) {
+		return res, err
+	}
+	if r.session.relogin(req.Context()) != nil {
+		return res, nil
+	}
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return res, nil
+		}
+	}
+	retry.Header.Set(restSessionHeader, r.session.REST.SessionID())
+	_ = res.Body.Close()
+	return r.roundTripper.RoundTrip(retry)
+}
@@ -0,0 +1,110 @@
+package session
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/methods"
+
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func newSimulator(t *testing.T) (*simulator.Service, *simulator.Server) {
+	model := simulator.VPX()
+	require.NoError(t, model.Create())
+	t.Cleanup(model.Remove)
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(server.Close)
+	return model.Service, server
+}
+
+func newSimulatorSession(t *testing.T) (*Session, *simulator.Service) {
+	service, server := newSimulator(t)
+	s, err := New(context.Background(), Config{
+		Endpoint: server.URL.Host,
+		Username: "user",
+		Password: "pass",
+		Insecure: true,
+	})
+	require.NoError(t, err)
+	return s, service
+}
+
+func TestReloginSOAP(t *testing.T) {
+	ctx := context.Background()
+	s, service := newSimulatorSession(t)
+	service.AddFaultRule(&simulator.FaultInjectionRule{
+		MethodName:  "CurrentTime",
+		ObjectType:  "*",
+		ObjectName:  "*",
+		Probability: 1.0,
+		FaultType:   simulator.FaultTypeNotAuthenticated,
+		MaxCount:    1,
+		Enabled:     true,
+	})
+
+	_, err := methods.GetCurrentTime(ctx, s.Client)
+	assert.NoError(t, err)
+}
+
+func TestReloginExpiredSession(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSimulatorSession(t)
+	require.NoError(t, session.NewManager(s.Client).Logout(ctx))
+	require.NoError(t, s.relogin(ctx))
+
+	userSession, err := session.NewManager(s.Client).UserSession(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, userSession)
+	assert.Equal(t, "user", s.Username())
+}
+
+func TestReloginREST(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSimulatorSession(t)
+	require.NoError(t, s.REST.Logout(ctx))
+
+	_, err := tags.NewManager(s.REST).GetCategories(ctx)
+	assert.NoError(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	s, _ := newSimulatorSession(t)
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	assert.NoError(t, s.Check(req))
+}
+
+func TestKeepAliveExpiredREST(t *testing.T) {
+	interval := KeepAliveInterval
+	KeepAliveInterval = 10 * time.Millisecond
+	t.Cleanup(func() { KeepAliveInterval = interval })
+	ctx := context.Background()
+	s, _ := newSimulatorSession(t)
+
+	// only the REST session expires, the SOAP one stays valid
+	s.REST.SessionID("expired")
+	require.Eventually(t, func() bool {
+		return s.REST.SessionID() != "expired"
+	}, 5*time.Second, 10*time.Millisecond, "the REST keepalive logs in again")
+
+	done := make(chan error)
+	go func() { done <- s.Login(ctx, "user", "pass") }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("login deadlocked with the keepalive handlers")
+	}
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	assert.NoError(t, s.Check(req))
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	opmetrics "github.com/awslabs/operatorpkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/vim25/soap"
+	"golang.org/x/time/rate"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	requestQueue = "request"
+	// TaskQueue labels the tasks waiting for a slot, the instance provider
+	// caps them where it waits on them
+	TaskQueue = "task"
+)
+
+// APIQueueDepth is the number of vCenter calls waiting for the rate limiter
+// or for a task slot
+var APIQueueDepth = opmetrics.NewPrometheusGauge(
+	crmetrics.Registry,
+	prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "vsphere",
+		Name:      "api_queue_depth",
+		Help:      "Number of vCenter API calls waiting to be sent. Labeled by vCenter and by queue, request for the rate limiter and task for the long-running task cap.",
+	},
+	[]string{"vcenter", "queue"},
+)
+
+// Limits throttle the API calls of a Session
+type Limits struct {
+	// QPS and Burst are a token bucket shared by the SOAP and REST clients,
+	// calls are not rate limited when QPS is 0
+	QPS   int
+	Burst int
+}
+
+type limiter struct {
+	vcenter  string
+	requests *rate.Limiter
+
+	waitingRequests atomic.Int64
+}
+
+func newLimiter(vcenter string, limits Limits) *limiter {
+	l := &limiter{vcenter: vcenter}
+	if limits.QPS > 0 {
+		l.requests = rate.NewLimiter(rate.Limit(limits.QPS), max(limits.Burst, 1))
+	}
+	return l
+}
+
+func (l *limiter) setDepth(queue string, depth int64) {
+	APIQueueDepth.Set(float64(depth), map[string]string{"vcenter": l.vcenter, "queue": queue})
+}
+
+// waitRequest blocks until the token bucket allows another call
+func (l *limiter) waitRequest(ctx context.Context) error {
+	if l.requests == nil {
+		return nil
+	}
+	l.setDepth(requestQueue, l.waitingRequests.Add(1))
+	defer func() { l.setDepth(requestQueue, l.waitingRequests.Add(-1)) }()
+	return l.requests.Wait(ctx)
+}
+
+// limitSOAP rate limits SOAP calls, tasks are only limited in the rate they
+// are started at
+type limitSOAP struct {
+	limiter      *limiter
+	roundTripper soap.RoundTripper
+}
+
+func (r *limitSOAP) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	if err := r.limiter.waitRequest(ctx); err != nil {
+		return err
+	}
+	return r.roundTripper.RoundTrip(ctx, req, res)
+}
+
+// limitREST rate limits REST calls with the token bucket of the SOAP client
+type limitREST struct {
+	limiter      *limiter
+	roundTripper http.RoundTripper
+}
+
+func (r *limitREST) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := r.limiter.waitRequest(req.Context()); err != nil {
+		return nil, err
+	}
+	return r.roundTripper.RoundTrip(req)
+}
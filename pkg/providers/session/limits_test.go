@@ -0,0 +1,44 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+)
+
+func TestWaitRequest(t *testing.T) {
+	l := newLimiter("vcenter", Limits{QPS: 1, Burst: 1})
+	require.NoError(t, l.waitRequest(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, l.waitRequest(ctx))
+
+	// calls aren't rate limited without QPS
+	assert.NoError(t, newLimiter("vcenter", Limits{}).waitRequest(ctx))
+}
+
+func TestRateLimitedTasks(t *testing.T) {
+	ctx := context.Background()
+	_, server := newSimulator(t)
+	s, err := New(ctx, Config{
+		Endpoint: server.URL.Host,
+		Username: "user",
+		Password: "pass",
+		Insecure: true,
+		Limits:   Limits{QPS: 100, Burst: 10},
+	})
+	require.NoError(t, err)
+
+	vm, err := find.NewFinder(s.Client, true).VirtualMachine(ctx, "/DC0/vm/DC0_H0_VM0")
+	require.NoError(t, err)
+	task, err := vm.PowerOff(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, task.Wait(ctx))
+	task, err = vm.PowerOn(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, task.Wait(ctx))
+}
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/session/keepalive"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// KeepAliveInterval is how long a session may be idle before the keepalive
+// handler checks it, well below the default vCenter idle timeout of 30 minutes
+var KeepAliveInterval = 5 * time.Minute
+
+// Config is the connection and credentials of a vCenter
+type Config struct {
+	Endpoint string
+	Username string
+	Password string
+	Insecure bool
+	// CAFile is a PEM bundle of the CAs that sign the vCenter certificate
+	CAFile string
+	// CAData is a PEM bundle trusted in addition to CAFile
+	CAData []byte
+	// Thumbprint is the SHA-256 thumbprint the vCenter certificate must match
+	Thumbprint string
+	// CertFile and KeyFile are a solution user certificate, used instead of
+	// Username and Password to get a token from the vCenter STS
+	CertFile string
+	KeyFile  string
+	// TokenFile is a pre-issued SAML token used instead of Username and
+	// Password, signed with CertFile when it is a holder-of-key token
+	TokenFile string
+	Limits    Limits
+}
+
+// Session holds the SOAP and REST clients of a vCenter. The clients are kept
+// for the life of the controller, logging in again replaces their sessions.
+// Both clients keep their sessions alive while idle and log in again with the
+// last credentials when vCenter reports the session as not authenticated.
+// The keepalive handlers only send the default requests, the REST one logs in
+// again through reloginREST; logging in from the SOAP handler would stop that
+// handler from its own goroutine and deadlock.
+type Session struct {
+	Client *vim25.Client
+	REST   *rest.Client
+
+	mu    sync.Mutex
+	user  *url.Userinfo
+	token *tokenAuth
+}
+
+func New(ctx context.Context, cfg Config) (*Session, error) {
+	u := &url.URL{
+		Scheme: "https",
+		Host:   cfg.Endpoint,
+		Path:   "/sdk",
+	}
+	token, err := newTokenAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+	soapClient := soap.NewClient(u, cfg.Insecure)
+	if err := configureTLS(soapClient, u.Host, cfg); err != nil {
+		return nil, err
+	}
+	vimClient, err := vim25.NewClient(ctx, soapClient)
+	if err != nil {
+		if soap.IsCertificateUntrusted(err) {
+			return nil, errors.Wrapf(err, "failed to verify the certificate of vCenter %s, configure its CA bundle or thumbprint", cfg.Endpoint)
+		}
+		return nil, errors.Wrapf(err, "failed to create vsphere client")
+	}
+	vimClient.UserAgent = "karpenter-vsphere"
+
+	s := &Session{
+		Client: vimClient,
+		REST:   rest.NewClient(vimClient),
+		token:  token,
+	}
+	limiter := newLimiter(cfg.Endpoint, cfg.Limits)
+	vimClient.RoundTripper = &limitSOAP{
+		limiter: limiter,
+		roundTripper: &reloginSOAP{
+			session:      s,
+			roundTripper: keepalive.NewHandlerSOAP(vimClient.RoundTripper, KeepAliveInterval, nil),
+		},
+	}
+	s.REST.Transport = &limitREST{
+		limiter: limiter,
+		roundTripper: &reloginREST{
+			session:      s,
+			roundTripper: keepalive.NewHandlerREST(s.REST, KeepAliveInterval, nil),
+		},
+	}
+	if err := s.Login(ctx, cfg.Username, cfg.Password); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Login establishes new SOAP and REST sessions with the credentials
+func (s *Session) Login(ctx context.Context, username, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.login(ctx, url.UserPassword(username, password))
+}
+
+// login replaces the sessions of both clients, vCenter refuses to log in again
+// while the SOAP session is still active so that one is logged out first.
+// Sessions authenticated by token ignore the user.
+func (s *Session) login(ctx context.Context, user *url.Userinfo) error {
+	ctx = withoutRelogin(ctx)
+	manager := session.NewManager(s.Client)
+	if userSession, _ := manager.UserSession(ctx); userSession != nil {
+		_ = manager.Logout(ctx)
+	}
+	if s.token != nil {
+		return s.loginByToken(ctx, manager)
+	}
+	if err := manager.Login(ctx, user); err != nil {
+		return errors.Wrapf(err, "failed to create client: failed to login")
+	}
+	if err := s.REST.Login(ctx, user); err != nil {
+		return errors.Wrapf(err, "failed to create client: failed to login to rest client")
+	}
+	s.user = user
+	return nil
+}
+
+// Username returns the user of the current session
+func (s *Session) Username() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.user.Username()
+}
+
+// CredentialsMatch reports whether the session was established with the credentials
+func (s *Session) CredentialsMatch(username, password string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, _ := s.user.Password()
+	return s.user.Username() == username && current == password
+}
@@ -0,0 +1,64 @@
+package session
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// configureTLS trusts the CA bundles of the config and pins the vCenter
+// certificate to its thumbprint
+func configureTLS(c *soap.Client, host string, cfg Config) error {
+	if cfg.CAFile != "" {
+		if err := c.SetRootCAs(cfg.CAFile); err != nil {
+			return fmt.Errorf("failed to load vCenter CA bundle %s: %w", cfg.CAFile, err)
+		}
+	}
+	tlsConfig := c.DefaultTransport().TLSClientConfig
+	if len(cfg.CAData) != 0 {
+		pool := tlsConfig.RootCAs
+		if pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(cfg.CAData) {
+			return errors.New("vCenter CA bundle contains no PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.Thumbprint == "" {
+		return nil
+	}
+	thumbprint, err := NormalizeThumbprint(cfg.Thumbprint)
+	if err != nil {
+		return err
+	}
+	// govmomi only checks the thumbprint of certificates no CA vouches for,
+	// the pin also applies to trusted ones
+	c.SetThumbprint(host, thumbprint)
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 || soap.ThumbprintSHA256(state.PeerCertificates[0]) != thumbprint {
+			return fmt.Errorf("vCenter %s certificate does not match thumbprint %s", host, thumbprint)
+		}
+		return nil
+	}
+	return nil
+}
+
+// NormalizeThumbprint returns a SHA-256 thumbprint in the colon separated
+// upper case form govmomi compares, colons are optional in the input
+func NormalizeThumbprint(thumbprint string) (string, error) {
+	sum, err := hex.DecodeString(strings.ReplaceAll(thumbprint, ":", ""))
+	if err != nil || len(sum) != 32 {
+		return "", fmt.Errorf("invalid vCenter thumbprint %q, expected a SHA-256 fingerprint", thumbprint)
+	}
+	pairs := make([]string, len(sum))
+	for i, b := range sum {
+		pairs[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(pairs, ":"), nil
+}
@@ -0,0 +1,49 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+func TestNormalizeThumbprint(t *testing.T) {
+	want := "AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89"
+	for _, in := range []string{want, "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"} {
+		thumbprint, err := NormalizeThumbprint(in)
+		assert.NoError(t, err)
+		assert.Equal(t, want, thumbprint)
+	}
+	_, err := NormalizeThumbprint("AB:CD:EF")
+	assert.Error(t, err)
+	_, err = NormalizeThumbprint("not a thumbprint")
+	assert.Error(t, err)
+}
+
+func TestCertificateVerification(t *testing.T) {
+	ctx := context.Background()
+	_, server := newSimulator(t)
+	caFile, err := server.CertificateFile()
+	require.NoError(t, err)
+	config := func(cfg Config) Config {
+		cfg.Endpoint, cfg.Username, cfg.Password = server.URL.Host, "user", "pass"
+		return cfg
+	}
+
+	_, err = New(ctx, config(Config{}))
+	assert.ErrorContains(t, err, "configure its CA bundle or thumbprint")
+
+	_, err = New(ctx, config(Config{Thumbprint: soap.ThumbprintSHA256(server.Certificate())}))
+	assert.NoError(t, err)
+
+	_, err = New(ctx, config(Config{Thumbprint: "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"}))
+	assert.ErrorContains(t, err, "does not match")
+
+	_, err = New(ctx, config(Config{CAFile: caFile}))
+	assert.NoError(t, err)
+
+	_, err = New(ctx, config(Config{CAFile: caFile, Thumbprint: "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"}))
+	assert.ErrorContains(t, err, "does not match")
+}
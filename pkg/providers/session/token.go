@@ -0,0 +1,74 @@
+package session
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/sts"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// tokenAuth logs in with a SAML token, either issued by the vCenter STS for
+// a solution user certificate or read from a pre-issued token file
+type tokenAuth struct {
+	certificate *tls.Certificate
+	tokenFile   string
+}
+
+func newTokenAuth(cfg Config) (*tokenAuth, error) {
+	if cfg.CertFile == "" && cfg.TokenFile == "" {
+		return nil, nil
+	}
+	auth := &tokenAuth{tokenFile: cfg.TokenFile}
+	if cfg.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load vCenter solution user certificate: %w", err)
+		}
+		auth.certificate = &certificate
+	}
+	return auth, nil
+}
+
+// signer returns the token to log in with, the token file is read on every
+// login so a renewed token is picked up
+func (a *tokenAuth) signer(ctx context.Context, c *vim25.Client) (*sts.Signer, error) {
+	if a.tokenFile != "" {
+		token, err := os.ReadFile(a.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vCenter SAML token: %w", err)
+		}
+		// a holder-of-key token is signed with the certificate, a bearer token without
+		return &sts.Signer{Token: strings.TrimSpace(string(token)), Certificate: a.certificate}, nil
+	}
+	stsClient, err := sts.NewClient(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vCenter STS client: %w", err)
+	}
+	signer, err := stsClient.Issue(ctx, sts.TokenRequest{Certificate: a.certificate, Delegatable: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue vCenter SAML token: %w", err)
+	}
+	return signer, nil
+}
+
+// loginByToken logs both clients in with the same SAML token
+func (s *Session) loginByToken(ctx context.Context, manager *session.Manager) error {
+	signer, err := s.token.signer(ctx, s.Client)
+	if err != nil {
+		return err
+	}
+	if err := manager.LoginByToken(s.Client.WithHeader(ctx, soap.Header{Security: signer})); err != nil {
+		return errors.Wrapf(err, "failed to create client: failed to login by token")
+	}
+	if err := s.REST.LoginByToken(s.REST.WithSigner(ctx, signer)); err != nil {
+		return errors.Wrapf(err, "failed to create client: failed to login to rest client by token")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/session"
+
+	_ "github.com/vmware/govmomi/lookup/simulator"
+	_ "github.com/vmware/govmomi/sts/simulator"
+)
+
+// writeSolutionUser writes a self-signed solution user certificate and key
+func writeSolutionUser(t *testing.T) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "karpenter"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600))
+	return certFile, keyFile
+}
+
+func TestLoginBySolutionUserCertificate(t *testing.T) {
+	ctx := context.Background()
+	_, server := newSimulator(t)
+	certFile, keyFile := writeSolutionUser(t)
+
+	s, err := New(ctx, Config{
+		Endpoint: server.URL.Host,
+		Insecure: true,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	})
+	require.NoError(t, err)
+	userSession, err := session.NewManager(s.Client).UserSession(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, userSession)
+	restSession, err := s.REST.Session(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, restSession)
+
+	// logging in again issues a new token
+	require.NoError(t, session.NewManager(s.Client).Logout(ctx))
+	assert.NoError(t, s.relogin(ctx))
+}
+
+func TestLoginByTokenFileMissing(t *testing.T) {
+	_, server := newSimulator(t)
+	_, err := New(context.Background(), Config{
+		Endpoint:  server.URL.Host,
+		Insecure:  true,
+		TokenFile: filepath.Join(t.TempDir(), "token.xml"),
+	})
+	assert.ErrorContains(t, err, "failed to read vCenter SAML token")
+}
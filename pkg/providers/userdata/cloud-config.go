@@ -25,7 +25,6 @@ runcmd:
 {{- end }}`
 
 func (r *CloudConfigRenderer) Render(data *DistroConfig, additional string) ([]byte, error) {
-	var additionalCfg DistroConfig
 	tpl := template.Must(template.New("cloud").Funcs(template.FuncMap{
 		"indent": func(spaces int, v string) string {
 			pad := bytes.Repeat([]byte(" "), spaces)
@@ -42,12 +41,8 @@ func (r *CloudConfigRenderer) Render(data *DistroConfig, additional string) ([]b
 		},
 	}).Parse(cloudConfigTpl))
 
-	if additional != "" {
-		if err := yaml.Unmarshal([]byte(additional), &additionalCfg); err != nil {
-			return nil, err
-		}
-		data.Files = append(data.Files, additionalCfg.Files...)
-		data.Commands = append(data.Commands, additionalCfg.Commands...)
+	if err := mergeAdditional(data, additional); err != nil {
+		return nil, err
 	}
 	var out bytes.Buffer
 	err := tpl.Execute(&out, map[string]any{
@@ -62,3 +57,18 @@ func (r *CloudConfigRenderer) Render(data *DistroConfig, additional string) ([]b
 
 	return out.Bytes(), nil
 }
+
+// mergeAdditional appends the write_files and runcmd entries of the additional
+// userdata to the distro config.
+func mergeAdditional(data *DistroConfig, additional string) error {
+	if additional == "" {
+		return nil
+	}
+	var additionalCfg DistroConfig
+	if err := yaml.Unmarshal([]byte(additional), &additionalCfg); err != nil {
+		return err
+	}
+	data.Files = append(data.Files, additionalCfg.Files...)
+	data.Commands = append(data.Commands, additionalCfg.Commands...)
+	return nil
+}
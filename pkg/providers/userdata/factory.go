@@ -19,6 +19,7 @@ type InitData struct {
 type InitType struct {
 	Format v1alpha1.UserDataType
 	Distro v1alpha1.Distro
+	OS     string
 }
 
 func NewInitData(taints []corev1.Taint, nodeName, endpoint, token, kubeversion string, userdata string) *InitData {
@@ -58,6 +59,17 @@ func (f *Factory) Build(initType *InitType) (Generator, Renderer, error) {
 	var generator Generator
 	var renderer Renderer
 
+	// Windows nodes are always bootstrapped with a PowerShell script,
+	// the userdata format only applies to Linux nodes.
+	if initType.OS == string(corev1.Windows) {
+		switch initType.Distro {
+		case v1alpha1.RKE2:
+			return &RKE2WindowsGenerator{}, &PowerShellRenderer{}, nil
+		default:
+			return nil, nil, fmt.Errorf("unsupported distro for windows")
+		}
+	}
+
 	switch initType.Distro {
 	case v1alpha1.RKE2:
 		generator = &RKE2Generator{}
@@ -0,0 +1,40 @@
+package userdata
+
+import (
+	"bytes"
+	"encoding/base64"
+	"text/template"
+)
+
+type PowerShellRenderer struct{}
+
+// Files are embedded base64 encoded, so their content can't end the string or
+// be subject to PowerShell expansion, and written as is.
+const powerShellTpl = `$ErrorActionPreference = "Stop"
+{{- range .Files }}
+New-Item -ItemType Directory -Force -Path (Split-Path -Parent "{{ .Path }}") | Out-Null
+[IO.File]::WriteAllBytes("{{ .Path }}", [Convert]::FromBase64String('{{ base64 .Content }}'))
+{{- end }}
+{{- range .Commands }}
+{{ . }}
+{{- end }}
+`
+
+func (r *PowerShellRenderer) Render(data *DistroConfig, additional string) ([]byte, error) {
+	tpl := template.Must(template.New("powershell").Funcs(template.FuncMap{
+		"base64": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	}).Parse(powerShellTpl))
+
+	if err := mergeAdditional(data, additional); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	err := tpl.Execute(&out, map[string]any{
+		"Files":    data.Files,
+		"Commands": data.Commands,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -34,10 +34,18 @@ func formatTaints(taints []corev1.Taint) []string {
 	return out
 }
 
-func getCommon(input *InitData, installCMD string) (*DistroConfig, error) {
+func renderRKE2Config(input *InitData) (string, error) {
 	tmpl := template.Must(template.New("init").Funcs(template.FuncMap{"taints": formatTaints}).Parse(RKE2ConfTemplate))
 	configData := &bytes.Buffer{}
 	err := tmpl.Execute(configData, input)
+	if err != nil {
+		return "", err
+	}
+	return configData.String(), nil
+}
+
+func getCommon(input *InitData, installCMD string) (*DistroConfig, error) {
+	configData, err := renderRKE2Config(input)
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +56,7 @@ func getCommon(input *InitData, installCMD string) (*DistroConfig, error) {
 				Owner:       "root:root",
 				Permissions: "0640",
 				Path:        "/etc/rancher/rke2/config.yaml",
-				Content:     configData.String()},
+				Content:     configData},
 		},
 		Commands: []string{
 			"sleep 10",
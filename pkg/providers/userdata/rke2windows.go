@@ -0,0 +1,39 @@
+package userdata
+
+import (
+	"fmt"
+)
+
+const (
+	rke2WindowsConfigPath = `C:/etc/rancher/rke2/config.yaml`
+	rke2WindowsBinPath    = `C:\var\lib\rancher\rke2\bin;C:\usr\local\bin`
+	installRKE2WindowsCmd = `Invoke-WebRequest -Uri https://raw.githubusercontent.com/rancher/rke2/master/install.ps1 -OutFile C:\install.ps1; C:\install.ps1 -Version %s`
+)
+
+type RKE2WindowsGenerator struct{}
+
+func (r *RKE2WindowsGenerator) Generate(input *InitData) (*DistroConfig, error) {
+	configData, err := renderRKE2Config(input)
+	if err != nil {
+		return nil, err
+	}
+	// Sysprep limits the computer name to 15 characters, pin the node name to
+	// the VM name so the cloud controller manager can match the node.
+	configData = fmt.Sprintf("%s\nnode-name: %s", configData, input.NodeName)
+	return &DistroConfig{
+		NodeName: input.NodeName,
+		Files: []File{
+			{
+				Path:    rke2WindowsConfigPath,
+				Content: configData,
+			},
+		},
+		Commands: []string{
+			fmt.Sprintf(`[Environment]::SetEnvironmentVariable("Path", $env:Path + ";%s", [EnvironmentVariableTarget]::Machine)`, rke2WindowsBinPath),
+			fmt.Sprintf(`$env:Path += ";%s"`, rke2WindowsBinPath),
+			fmt.Sprintf(installRKE2WindowsCmd, input.KubeVersion),
+			"rke2.exe agent service --add",
+			"Start-Service rke2",
+		},
+	}, nil
+}
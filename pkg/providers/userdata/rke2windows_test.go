@@ -0,0 +1,56 @@
+package userdata
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/absaoss/karpenter-provider-vsphere/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const expectedRKE2WindowsPowerShell = `$ErrorActionPreference = "Stop"
+New-Item -ItemType Directory -Force -Path (Split-Path -Parent "C:/etc/rancher/rke2/config.yaml") | Out-Null
+[IO.File]::WriteAllBytes("C:/etc/rancher/rke2/config.yaml", [Convert]::FromBase64String('c2VydmVyOiAKa3ViZWxldC1hcmc6CiAgLSAtLWNsb3VkLXByb3ZpZGVyPWV4dGVybmFsCnRva2VuOiBmb28Kbm9kZS10YWludDoKICAtIGthcnBlbnRlci5zaC9jb250cm9sbGVyPXRydWU6Tm9TY2hlZHVsZQpub2RlLW5hbWU6IHRlc3Rub2Rl'))
+[Environment]::SetEnvironmentVariable("Path", $env:Path + ";C:\var\lib\rancher\rke2\bin;C:\usr\local\bin", [EnvironmentVariableTarget]::Machine)
+$env:Path += ";C:\var\lib\rancher\rke2\bin;C:\usr\local\bin"
+Invoke-WebRequest -Uri https://raw.githubusercontent.com/rancher/rke2/master/install.ps1 -OutFile C:\install.ps1; C:\install.ps1 -Version v1.35.4+rke2r1
+rke2.exe agent service --add
+Start-Service rke2
+foo bar baz
+`
+
+func TestRKE2WindowsPowerShell(t *testing.T) {
+	initType := &InitType{
+		Distro: v1alpha1.Distro("rke2"),
+		Format: v1alpha1.UserDataTypeCloudConfig,
+		OS:     string(corev1.Windows),
+	}
+	factory := &Factory{}
+	gen, par, err := factory.Build(initType)
+	// it should not err
+	assert.Nil(t, err)
+	data, err := gen.Generate(initData)
+	assert.Nil(t, err)
+	res, err := par.Render(data, extraCloudConfigCmd)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedRKE2WindowsPowerShell, string(res))
+}
+
+func TestPowerShellEncodesFiles(t *testing.T) {
+	content := "'@\nRemove-Item -Recurse C:\\\n$env:TOKEN"
+	res, err := (&PowerShellRenderer{}).Render(&DistroConfig{Files: []File{{Path: "C:/config.yaml", Content: content}}}, "")
+	assert.Nil(t, err)
+	assert.NotContains(t, string(res), "Remove-Item")
+	assert.Contains(t, string(res), base64.StdEncoding.EncodeToString([]byte(content)))
+}
+
+func TestWindowsUnsupportedDistro(t *testing.T) {
+	initType := &InitType{
+		Distro: v1alpha1.Distro("rke2airgapped"),
+		OS:     string(corev1.Windows),
+	}
+	factory := &Factory{}
+	_, _, err := factory.Build(initType)
+	assert.NotNil(t, err)
+}
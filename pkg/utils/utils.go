@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/awslabs/operatorpkg/serrors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
 )
 
 var (
-	instanceIDRegex = regexp.MustCompile(`(?P<Provider>.*)://(?P<InstanceID>.*)`)
+	instanceIDRegex        = regexp.MustCompile(`(?P<Provider>.*)://(?P<InstanceID>.*)`)
+	invalidLabelValueChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
 )
 
 func GetAllSingleValuedRequirementLabels(instanceType *cloudprovider.InstanceType) map[string]string {
@@ -51,3 +54,12 @@ func GiToKb(size int64) int64 {
 func GiToMb(size int64) int64 {
 	return size * 1024
 }
+
+// SanitizeLabelValue turns a vSphere inventory name into a valid label value
+func SanitizeLabelValue(v string) string {
+	v = invalidLabelValueChars.ReplaceAllString(v, "-")
+	if len(v) > validation.LabelValueMaxLength {
+		v = v[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(v, "-_.")
+}
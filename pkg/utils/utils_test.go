@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestSanitizeLabelValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "valid name", value: "esxi-01.lab.local", expected: "esxi-01.lab.local"},
+		{name: "spaces and slashes", value: "Compute Cluster/A", expected: "Compute-Cluster-A"},
+		{name: "leading and trailing symbols", value: "(cluster)", expected: "cluster"},
+		{name: "empty", value: "", expected: ""},
+		{name: "too long", value: strings.Repeat("a", 70), expected: strings.Repeat("a", validation.LabelValueMaxLength)},
+		{name: "symbol at the truncation", value: strings.Repeat("a", 62) + " b", expected: strings.Repeat("a", 62)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sanitized := SanitizeLabelValue(tt.value)
+			assert.Equal(t, tt.expected, sanitized)
+			assert.Empty(t, validation.IsValidLabelValue(sanitized))
+		})
+	}
+}